@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alert actions
+const (
+	alertBell  = "bell"
+	alertFlash = "flash"
+	alertSound = "sound"
+)
+
+// AlertConfig controls how the sysop is signalled when callers arrive.
+type AlertConfig struct {
+	Connect []string // Actions when a caller connects
+	Login   []string // Actions when a user logs in
+	NewUser []string // Actions when a new user signs up
+	Watch   []string // Actions when one of Users logs in, instead of Login
+	Users   []string
+
+	Suspicious []string // Actions when an IP is flagged by the detector
+
+	SoundCommand string        // Command the sound file is passed to, e.g. "aplay -q"
+	Sound        string        // Sound file for the sound action
+	FlashTime    time.Duration // How long a node row flashes
+
+	// Quiet hours silence the bell and sound, flashing still happens
+	QuietFrom, QuietTo int // Minutes after midnight, -1 when there are none
+}
+
+// parseAlertActions reads a comma separated list of actions.
+func parseAlertActions(value string) ([]string, error) {
+	var actions []string
+	for _, action := range strings.Split(value, ",") {
+		action = strings.ToLower(strings.TrimSpace(action))
+		switch action {
+		case "":
+			continue
+		case alertBell, alertFlash, alertSound:
+			actions = append(actions, action)
+		default:
+			return nil, fmt.Errorf("unknown alert %q", action)
+		}
+	}
+	return actions, nil
+}
+
+// actions returns the alerts configured for an event.
+func (config AlertConfig) actions(ev Event) []string {
+	switch ev.Type {
+	case EventConnect:
+		return config.Connect
+	case EventNewUser:
+		return config.NewUser
+	case EventLogin:
+		for _, user := range config.Users {
+			if strings.EqualFold(user, ev.User) {
+				return config.Watch
+			}
+		}
+		return config.Login
+	}
+	return nil
+}
+
+// quiet reports whether t falls in the quiet hours.
+func (config AlertConfig) quiet(t time.Time) bool {
+	return config.QuietFrom >= 0 && inTimeRange(t, config.QuietFrom, config.QuietTo)
+}
+
+// Alert signals the sysop about an event with the configured actions.
+func Alert(config AlertConfig, ev Event, screen *Screen) {
+	alert(config, config.actions(ev), ev.Node, screen)
+}
+
+// AlertSuspect signals that an IP was flagged, flashing the node it was
+// last on, and tells the sysop why in the footer.
+func AlertSuspect(config AlertConfig, suspect Suspect, screen *Screen) {
+	alert(config, config.Suspicious, suspect.Node, screen)
+	screen.Notify("Suspicious: " + suspect.String())
+}
+
+// alert runs alert actions for a node.
+func alert(config AlertConfig, actions []string, node string, screen *Screen) {
+	now := time.Now()
+	for _, action := range actions {
+		switch action {
+		case alertBell:
+			if !config.quiet(now) {
+				fmt.Fprint(Output, Bel)
+			}
+		case alertFlash:
+			if nodeNum, err := strconv.Atoi(node); err == nil {
+				screen.Flash(nodeNum, now.Add(config.FlashTime))
+			}
+		case alertSound:
+			if !config.quiet(now) {
+				playSound(config.SoundCommand, config.Sound)
+			}
+		}
+	}
+}
+
+// playSound runs the sound command on a file in the background.
+func playSound(command, file string) {
+	args := strings.Fields(command)
+	if len(args) == 0 || file == "" {
+		log.Printf("Sound alert needs a sound command and a sound file")
+		return
+	}
+	cmd := exec.Command(args[0], append(args[1:], file)...)
+	if err := cmd.Start(); err != nil {
+		log.Printf("Error playing sound %s: %v", file, err)
+		return
+	}
+	go cmd.Wait()
+}
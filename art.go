@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Art selection modes for an art widget's pool
+const (
+	artModeRotate = "rotate"
+	artModeRandom = "random"
+)
+
+var (
+	timeRangePattern = regexp.MustCompile(`^(\d{1,2}):(\d{2})-(\d{1,2}):(\d{2})$`)
+	dateRangePattern = regexp.MustCompile(`^(\d{2}-\d{2})(?:\.\.(\d{2}-\d{2}))?$`)
+)
+
+// ArtRule shows a file during a time of day and/or on a range of dates.
+type ArtRule struct {
+	FromMinute, ToMinute int    // Minutes after midnight, -1 when any time
+	FromDate, ToDate     string // MM-DD, empty when any date
+	File                 string
+}
+
+// parseArtRule reads a schedule entry: one or more conditions followed by the
+// file, e.g. "18:00-06:00 night.ans", "12-24..12-26 xmas.ans" or
+// "10-31 20:00-23:59 spooky.ans".
+func parseArtRule(entry string) (ArtRule, error) {
+	fields := strings.Fields(entry)
+	if len(fields) < 2 {
+		return ArtRule{}, fmt.Errorf("schedule %q needs a condition and a file", entry)
+	}
+	rule := ArtRule{FromMinute: -1, ToMinute: -1, File: fields[len(fields)-1]}
+	for _, condition := range fields[:len(fields)-1] {
+		if matches := timeRangePattern.FindStringSubmatch(condition); matches != nil {
+			rule.FromMinute = atoiOrZero(matches[1])*60 + atoiOrZero(matches[2])
+			rule.ToMinute = atoiOrZero(matches[3])*60 + atoiOrZero(matches[4])
+		} else if matches := dateRangePattern.FindStringSubmatch(condition); matches != nil {
+			rule.FromDate, rule.ToDate = matches[1], matches[2]
+			if rule.ToDate == "" {
+				rule.ToDate = rule.FromDate
+			}
+		} else {
+			return ArtRule{}, fmt.Errorf("schedule %q: unknown condition %q", entry, condition)
+		}
+	}
+	return rule, nil
+}
+
+// Matches reports whether the rule applies at t. Ranges may wrap around
+// midnight or the end of the year.
+func (rule ArtRule) Matches(t time.Time) bool {
+	if rule.FromDate != "" && !inRange(t.Format("01-02"), rule.FromDate, rule.ToDate) {
+		return false
+	}
+	if rule.FromMinute >= 0 {
+		return inTimeRange(t, rule.FromMinute, rule.ToMinute)
+	}
+	return true
+}
+
+// parseTimeRange reads a time of day range such as "18:00-06:00" as minutes
+// after midnight.
+func parseTimeRange(s string) (from, to int, ok bool) {
+	matches := timeRangePattern.FindStringSubmatch(s)
+	if matches == nil {
+		return 0, 0, false
+	}
+	from = atoiOrZero(matches[1])*60 + atoiOrZero(matches[2])
+	to = atoiOrZero(matches[3])*60 + atoiOrZero(matches[4])
+	return from, to, true
+}
+
+// inTimeRange reports whether t's time of day falls in a range of minutes
+// after midnight, which may wrap around midnight.
+func inTimeRange(t time.Time, from, to int) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+func inRange(value, from, to string) bool {
+	if from <= to {
+		return value >= from && value <= to
+	}
+	return value >= from || value <= to
+}
+
+// ArtPool picks the file an art widget shows from a glob or directory of
+// files, in rotation or at random, unless a schedule rule matches.
+type ArtPool struct {
+	widget    Widget
+	artDir    string
+	index     int
+	current   string
+	picked    time.Time
+	scheduled bool // current came from a schedule rule
+}
+
+// NewArtPool creates the pool for an art widget, resolving files against artDir.
+func NewArtPool(widget Widget, artDir string) *ArtPool {
+	return &ArtPool{widget: widget, artDir: artDir, index: -1}
+}
+
+// files lists the pool, read again on every pick so new art is picked up
+// without a restart.
+func (p *ArtPool) files() []string {
+	pattern := p.resolve(p.widget.File)
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		pattern = filepath.Join(pattern, "*.ans")
+	}
+	files, _ := filepath.Glob(pattern)
+	sort.Strings(files)
+	if len(files) == 0 {
+		// Not a pattern, or nothing matched; let the draw report it
+		return []string{pattern}
+	}
+	return files
+}
+
+func (p *ArtPool) resolve(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(p.artDir, file)
+}
+
+// scheduledFile returns the file of the first matching schedule rule, if any.
+func (p *ArtPool) scheduledFile(now time.Time) string {
+	for _, rule := range p.widget.Schedule {
+		if rule.Matches(now) {
+			return p.resolve(rule.File)
+		}
+	}
+	return ""
+}
+
+// Pick chooses the next file to show.
+func (p *ArtPool) Pick(now time.Time) string {
+	p.picked = now
+	if file := p.scheduledFile(now); file != "" {
+		p.current, p.scheduled = file, true
+		return file
+	}
+	p.scheduled = false
+
+	files := p.files()
+	if p.widget.Mode == artModeRandom {
+		p.index = rand.Intn(len(files))
+	} else {
+		p.index = (p.index + 1) % len(files)
+	}
+	p.current = files[p.index]
+	return p.current
+}
+
+// Due reports whether the art should change: the rotation interval has
+// passed or a different schedule rule now applies.
+func (p *ArtPool) Due(now time.Time) bool {
+	if p.current == "" {
+		return true
+	}
+	if file := p.scheduledFile(now); file != "" || p.scheduled {
+		return file != p.current
+	}
+	return p.widget.Interval > 0 && now.Sub(p.picked) >= p.widget.Interval
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Blocklist formats
+const (
+	banFormatTalisman  = "talisman"   // One IP per line, for Talisman's IP block list
+	banFormatHostsDeny = "hosts.deny" // "ALL: ip" lines in a marked block of hosts.deny
+	banFormatNftables  = "nftables"   // nft commands filling a set, run with nft -f
+)
+
+// Who a ban or unban is recorded as made by
+const (
+	banByWFC   = "wfc"
+	banBySysop = "sysop"
+)
+
+// Audit trail actions
+const (
+	banActionBan    = "ban"
+	banActionUnban  = "unban"
+	banActionExpire = "expire"
+)
+
+// Markers around the WFC's lines in hosts.deny; the rest of the file is left alone
+const (
+	hostsDenyBegin = "# BEGIN talisman-wfc bans"
+	hostsDenyEnd   = "# END talisman-wfc bans"
+)
+
+// BanConfig controls the ban list.
+type BanConfig struct {
+	Auto     bool          // Ban IPs flagged by the detector
+	Duration time.Duration // How long bans last, 0 for good
+	File     string        // Blocklist written for the BBS or firewall, none when empty
+	Format   string        // talisman, hosts.deny or nftables
+	NftSet   string        // "family table set" filled with IPv4 bans, for nftables
+	NftSet6  string        // Set filled with IPv6 bans, IPv6 bans are left out when empty
+	Command  string        // Run after the blocklist is written, e.g. to reload the firewall
+	Audit    string        // Audit trail, which also keeps the bans across restarts
+}
+
+// Ban is an IP barred from the BBS.
+type Ban struct {
+	IP      string
+	Reason  string
+	By      string
+	Added   time.Time
+	Expires time.Time // Zero for good
+}
+
+// banRecord is a line of the audit trail.
+type banRecord struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	IP      string    `json:"ip"`
+	By      string    `json:"by,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Expires time.Time `json:"expires"` // Zero for good
+}
+
+// Bans is the ban list. Every change is appended to the audit trail and the
+// blocklist file is rewritten from the bans still in force.
+type Bans struct {
+	config BanConfig
+	bans   map[string]Ban
+	errors chan string
+}
+
+// LoadBans rebuilds the ban list from the audit trail, drops bans that ran
+// out while the WFC was down and writes the blocklist.
+func LoadBans(config BanConfig) (*Bans, error) {
+	b := &Bans{config: config, bans: make(map[string]Ban), errors: make(chan string, 16)}
+	if config.Audit != "" {
+		file, err := os.Open(config.Audit)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			defer file.Close()
+			scanner := bufio.NewScanner(file)
+			for line := 1; scanner.Scan(); line++ {
+				var record banRecord
+				if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+					// Such as a line left half written by a crash
+					log.Printf("Skipping %s line %d: %v", config.Audit, line, err)
+					continue
+				}
+				if record.Action == banActionBan {
+					ban := Ban{IP: record.IP, Reason: record.Reason, By: record.By, Added: record.Time, Expires: record.Expires}
+					// A repeat ban only pushed the expiry back
+					if existing, banned := b.bans[record.IP]; banned {
+						ban.Added = existing.Added
+					}
+					b.bans[record.IP] = ban
+				} else {
+					delete(b.bans, record.IP)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := b.Expire(time.Now()); err != nil {
+		return nil, err
+	}
+	return b, b.write()
+}
+
+// List returns the bans in force, newest first.
+func (b *Bans) List() []Ban {
+	bans := make([]Ban, 0, len(b.bans))
+	for _, ban := range b.bans {
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Added.After(bans[j].Added) })
+	return bans
+}
+
+// Banned reports whether an IP is on the list.
+func (b *Bans) Banned(address string) bool {
+	ip := parseIP(address)
+	return ip != nil && b.bans[ip.String()].IP != ""
+}
+
+// Ban bars an IP for the configured duration. Banning an IP that's already
+// banned only pushes its expiry back.
+func (b *Bans) Ban(address, reason, by string) error {
+	ip := parseIP(address)
+	if ip == nil {
+		return fmt.Errorf("%q is not an IP address", address)
+	}
+	now := time.Now()
+	ban, banned := b.bans[ip.String()]
+	if banned && ban.Expires.IsZero() {
+		// Banned for good already
+		return nil
+	}
+	if !banned {
+		ban = Ban{IP: ip.String(), Reason: reason, By: by, Added: now}
+	}
+	if b.config.Duration > 0 {
+		ban.Expires = now.Add(b.config.Duration)
+	} else {
+		ban.Expires = time.Time{}
+	}
+	b.bans[ban.IP] = ban
+	if err := b.audit(banRecord{Time: now, Action: banActionBan, IP: ban.IP, By: ban.By, Reason: ban.Reason, Expires: ban.Expires}); err != nil {
+		return err
+	}
+	if banned {
+		// The blocklist hasn't changed
+		return nil
+	}
+	return b.write()
+}
+
+// Unban lifts the ban on an IP.
+func (b *Bans) Unban(address, by string) error {
+	ip := parseIP(address)
+	if ip == nil {
+		return fmt.Errorf("%q is not an IP address", address)
+	}
+	if !b.Banned(address) {
+		return fmt.Errorf("%s is not banned", ip)
+	}
+	delete(b.bans, ip.String())
+	if err := b.audit(banRecord{Time: time.Now(), Action: banActionUnban, IP: ip.String(), By: by}); err != nil {
+		return err
+	}
+	return b.write()
+}
+
+// Expire lifts the bans that have run out and returns how many did.
+func (b *Bans) Expire(now time.Time) (int, error) {
+	expired := 0
+	for ip, ban := range b.bans {
+		if ban.Expires.IsZero() || now.Before(ban.Expires) {
+			continue
+		}
+		delete(b.bans, ip)
+		expired++
+		if err := b.audit(banRecord{Time: now, Action: banActionExpire, IP: ip}); err != nil {
+			return expired, err
+		}
+	}
+	if expired == 0 {
+		return 0, nil
+	}
+	return expired, b.write()
+}
+
+// audit appends a record to the audit trail.
+func (b *Bans) audit(record banRecord) error {
+	if b.config.Audit == "" {
+		return nil
+	}
+	file, err := os.OpenFile(b.config.Audit, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	// Start on a new line after a line left half written by a crash
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			writer.WriteByte('\n')
+		}
+	}
+	if err := json.NewEncoder(writer).Encode(record); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// write replaces the blocklist with the bans in force and runs the command.
+func (b *Bans) write() error {
+	if b.config.File == "" {
+		return nil
+	}
+	ips := make([]string, 0, len(b.bans))
+	for ip := range b.bans {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	var content strings.Builder
+	switch b.config.Format {
+	case banFormatHostsDeny:
+		existing, err := os.ReadFile(b.config.File)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		content.WriteString(outsideBlock(string(existing), hostsDenyBegin, hostsDenyEnd))
+		content.WriteString(hostsDenyBegin + "\n")
+		for _, ip := range ips {
+			if strings.Contains(ip, ":") {
+				ip = "[" + ip + "]"
+			}
+			fmt.Fprintf(&content, "ALL: %s\n", ip)
+		}
+		content.WriteString(hostsDenyEnd + "\n")
+	case banFormatNftables:
+		var ipv4, ipv6 []string
+		for _, ip := range ips {
+			if parseIP(ip).To4() != nil {
+				ipv4 = append(ipv4, ip)
+			} else {
+				ipv6 = append(ipv6, ip)
+			}
+		}
+		content.WriteString("# Written by talisman-wfc, load with nft -f\n")
+		for _, set := range []struct {
+			name string
+			ips  []string
+		}{{b.config.NftSet, ipv4}, {b.config.NftSet6, ipv6}} {
+			if set.name == "" {
+				continue
+			}
+			fmt.Fprintf(&content, "flush set %s\n", set.name)
+			if len(set.ips) > 0 {
+				fmt.Fprintf(&content, "add element %s { %s }\n", set.name, strings.Join(set.ips, ", "))
+			}
+		}
+	default:
+		for _, ip := range ips {
+			content.WriteString(ip + "\n")
+		}
+	}
+
+	// Replace the file in one step so the BBS or firewall never reads half a list
+	tmpPath := filepath.Join(filepath.Dir(b.config.File), "."+filepath.Base(b.config.File)+".tmp")
+	if err := os.WriteFile(tmpPath, []byte(content.String()), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, b.config.File); err != nil {
+		return err
+	}
+	b.reload()
+	return nil
+}
+
+// Errors delivers a message for each ban command that failed, for the WFC to
+// show in the footer rather than print over the screen.
+func (b *Bans) Errors() <-chan string {
+	return b.errors
+}
+
+// reload runs the command that makes the BBS or firewall pick up the new
+// blocklist, in the background.
+func (b *Bans) reload() {
+	if b.config.Command == "" {
+		return
+	}
+	args := strings.Fields(b.config.Command)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			// Dropped when the WFC is behind on them
+			select {
+			case b.errors <- fmt.Sprintf("Error running ban command %q: %v: %s", b.config.Command, err, strings.TrimSpace(string(output))):
+			default:
+			}
+		}
+	}()
+}
+
+// outsideBlock returns text without the lines from begin to end, ending in a
+// newline unless it's empty.
+func outsideBlock(text, begin, end string) string {
+	var kept strings.Builder
+	inside := false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch strings.TrimSpace(line) {
+		case begin:
+			inside = true
+			continue
+		case end:
+			inside = false
+			continue
+		}
+		if !inside && line != "" {
+			kept.WriteString(line)
+		}
+	}
+	result := kept.String()
+	if result != "" && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	return result
+}
+
+// drawBanScreen lists the bans in force, with the suspicious IPs under them,
+// in place of the WFC screen.
+func (s *Screen) drawBanScreen() {
+	MoveCursor(1, 1)
+	PrintSpaces(s.Width, s.Theme.Footer)
+	MoveCursor(1, 1)
+	fmt.Fprint(Output, s.Theme.Footer+s.Theme.FooterLabel+" Bans"+Reset)
+
+	reasonWidth := max(0, s.Width-1-detailIPWidth-7-18)
+	MoveCursor(1, 3)
+	fmt.Fprint(Output,
+		s.Theme.Background+" "+
+			formatCell("Address", detailIPWidth, s.Theme.LocationLabel)+
+			formatCell("By", 7, s.Theme.LocationLabel)+
+			formatCell("Until", 18, s.Theme.LocationLabel)+
+			formatCell("Reason", reasonWidth, s.Theme.LocationLabel),
+	)
+
+	// The rest of the screen is shared with the suspicious IPs
+	suspectRows := 5
+	rows := max(1, s.Height-6-suspectRows)
+	var bans []Ban
+	if s.Bans != nil {
+		bans = s.Bans.List()
+	}
+	for i := 0; i < rows && i < len(bans); i++ {
+		ban := bans[i]
+		until := "for good"
+		if !ban.Expires.IsZero() {
+			until = ban.Expires.Format("2006-01-02 15:04")
+		}
+		MoveCursor(1, 4+i)
+		fmt.Fprint(Output,
+			s.Theme.Background+" "+
+				formatCell(s.address(ban.IP), detailIPWidth, s.Theme.User)+
+				formatCell(ban.By, 7, s.Theme.Location)+
+				formatCell(until, 18, s.Theme.Location)+
+				formatCell(ban.Reason, reasonWidth, s.Theme.Location),
+		)
+	}
+	if len(bans) == 0 {
+		MoveCursor(1, 4)
+		fmt.Fprint(Output, formatCell(" None", detailIPWidth+1, s.Theme.Location))
+	}
+	s.drawSuspects(Rect{X: 1, Y: s.Height - 1 - suspectRows, W: s.Width, H: suspectRows})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBansRepeat(t *testing.T) {
+	dir := t.TempDir()
+	config := BanConfig{
+		Duration: time.Hour,
+		File:     filepath.Join(dir, "blocked.txt"),
+		Format:   banFormatTalisman,
+		Audit:    filepath.Join(dir, "wfc-bans.jsonl"),
+	}
+	bans, err := LoadBans(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bans.Ban("203.0.113.7:51234", "flood: 10 connections, 0 logins", banByWFC); err != nil {
+		t.Fatal(err)
+	}
+	first := bans.List()[0]
+	time.Sleep(10 * time.Millisecond)
+	if err := bans.Ban("203.0.113.7", "brute force: 12 connections, 0 logins", banByWFC); err != nil {
+		t.Fatalf("banning again: %v", err)
+	}
+	list := bans.List()
+	if len(list) != 1 {
+		t.Fatalf("%d bans, want 1", len(list))
+	}
+	if again := list[0]; again.Reason != first.Reason || !again.Added.Equal(first.Added) || !again.Expires.After(first.Expires) {
+		t.Errorf("ban after banning again = %+v, want %+v with a later expiry", again, first)
+	}
+
+	blocklist, err := os.ReadFile(config.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(blocklist) != "203.0.113.7\n" {
+		t.Errorf("blocklist = %q, want the IP once", blocklist)
+	}
+
+	// The refreshed ban is what's restored
+	reloaded, err := LoadBans(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored := reloaded.List(); len(restored) != 1 || !restored[0].Added.Equal(list[0].Added) || !restored[0].Expires.Equal(list[0].Expires) {
+		t.Errorf("restored bans = %+v, want %+v", restored, list)
+	}
+}
+
+func TestBansExpire(t *testing.T) {
+	dir := t.TempDir()
+	config := BanConfig{
+		Duration: time.Hour,
+		File:     filepath.Join(dir, "hosts.deny"),
+		Format:   banFormatHostsDeny,
+		Audit:    filepath.Join(dir, "wfc-bans.jsonl"),
+	}
+	if err := os.WriteFile(config.File, []byte("sshd: 192.0.2.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bans, err := LoadBans(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.Ban("203.0.113.7", "banned by the sysop", banBySysop); err != nil {
+		t.Fatal(err)
+	}
+	if !bans.Banned("203.0.113.7") {
+		t.Fatal("IP isn't banned")
+	}
+	if expired, err := bans.Expire(time.Now().Add(2 * time.Hour)); err != nil || expired != 1 {
+		t.Fatalf("Expire = %d, %v; want 1 ban lifted", expired, err)
+	}
+	if bans.Banned("203.0.113.7") {
+		t.Error("IP still banned after expiring")
+	}
+
+	content, err := os.ReadFile(config.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "sshd: 192.0.2.1\n") || strings.Contains(string(content), "203.0.113.7") {
+		t.Errorf("hosts.deny = %q, want the sysop's line kept and the ban gone", content)
+	}
+}
+
+func TestBansCommandError(t *testing.T) {
+	dir := t.TempDir()
+	bans, err := LoadBans(BanConfig{
+		Duration: time.Hour,
+		File:     filepath.Join(dir, "blocked.txt"),
+		Format:   banFormatTalisman,
+		Command:  "false",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Loading runs the command once already
+	select {
+	case <-bans.Errors():
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed ban command not reported on loading")
+	}
+
+	if err := bans.Ban("203.0.113.7", "banned by the sysop", banBySysop); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case text := <-bans.Errors():
+		if !strings.Contains(text, `ban command "false"`) {
+			t.Errorf("error = %q, want the failed ban command", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed ban command not reported")
+	}
+}
+
+func TestBansTruncatedAudit(t *testing.T) {
+	dir := t.TempDir()
+	config := BanConfig{
+		Duration: time.Hour,
+		File:     filepath.Join(dir, "blocked.txt"),
+		Format:   banFormatTalisman,
+		Audit:    filepath.Join(dir, "wfc-bans.jsonl"),
+	}
+	bans, err := LoadBans(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.Ban("203.0.113.7", "banned by the sysop", banBySysop); err != nil {
+		t.Fatal(err)
+	}
+	// A crash while the next ban was being written
+	file, err := os.OpenFile(config.Audit, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"action":"ban","ip":"198.51`)
+	file.Close()
+
+	bans, err = LoadBans(config)
+	if err != nil {
+		t.Fatalf("loading after a truncated line: %v", err)
+	}
+	if err := bans.Ban("192.0.2.1", "banned by the sysop", banBySysop); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadBans(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.Banned("203.0.113.7") || !reloaded.Banned("192.0.2.1") || len(reloaded.List()) != 2 {
+		t.Errorf("restored bans = %+v, want 203.0.113.7 and 192.0.2.1", reloaded.List())
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Built-in templates, used when no templates directory is configured
+//
+//go:embed bulletins/*.ans
+var defaultBulletins embed.FS
+
+var (
+	// {NAME} or {NAME:width} placeholders in bulletin templates
+	placeholderPattern = regexp.MustCompile(`\{([A-Z0-9_]+)(?::(\d+))?\}`)
+)
+
+// bulletinValues builds the placeholder values from the current state and
+// the caller history.
+func bulletinValues(state *State, history *History, systemName string) map[string]string {
+	now := time.Now()
+	today := state.TodayStats()
+	values := map[string]string{
+		"SYSTEM":          systemName,
+		"DATE":            now.Format("01/02/06"),
+		"TIME":            now.Format("15:04"),
+		"CALLS_TODAY":     strconv.Itoa(today.Calls),
+		"NEWUSERS_TODAY":  strconv.Itoa(today.NewUsers),
+		"POSTS_TODAY":     strconv.Itoa(today.Posts),
+		"DOORS_TODAY":     strconv.Itoa(today.Doors),
+		"BOTS_TODAY":      strconv.Itoa(today.Bots),
+		"FAILED_TODAY":    strconv.Itoa(today.Failed),
+		"BADPASS_TODAY":   strconv.Itoa(today.BadPasswords),
+		"ABANDONED_TODAY": strconv.Itoa(today.Abandoned),
+		"ONLINE":          strconv.Itoa(state.Online()),
+		"LAST_USER":       state.LastUser,
+	}
+	for i, call := range state.RecentCalls(10) {
+		prefix := fmt.Sprintf("CALLER%d_", i+1)
+		values[prefix+"USER"] = call.User
+		values[prefix+"NODE"] = call.Node
+		values[prefix+"DATE"] = call.Time.Format("01/02/06")
+		values[prefix+"TIME"] = call.Time.Format("15:04")
+	}
+
+	// Leaderboards, e.g. {MONTH_CALLS1_USER} and {MONTH_CALLS1_VALUE}
+	for period, info := range leaderPeriods {
+		for _, board := range ComputeLeaderboards(history.Sessions(), periodStart(period, now)) {
+			for i, leader := range board.Leaders {
+				prefix := fmt.Sprintf("%s_%s%d_", info.Key, board.Key, i+1)
+				values[prefix+"USER"] = leader.Name
+				values[prefix+"VALUE"] = board.Value(leader.Count)
+			}
+		}
+	}
+	return values
+}
+
+// fillTemplate replaces the placeholders in a CP437 template. Unknown
+// placeholders become blank so unused last caller slots stay empty. Color
+// codes in the values are removed, so they're shown in the template's colors.
+func fillTemplate(template string, values map[string]string) string {
+	encoder := encoding.ReplaceUnsupported(charmap.CodePage437.NewEncoder())
+	return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		matches := placeholderPattern.FindStringSubmatch(placeholder)
+		value, err := encoder.String(StripColorCodes(values[matches[1]]))
+		if err != nil {
+			value = ""
+		}
+		if matches[2] != "" {
+			width, _ := strconv.Atoi(matches[2])
+			value = PadOrTruncate(value, width)
+		}
+		return value
+	})
+}
+
+// writeBulletins renders every template into the output directory as a .ans
+// file and a plain .asc fallback.
+func writeBulletins(config BulletinConfig, state *State, history *History, systemName string) error {
+	templates, err := fs.Sub(defaultBulletins, "bulletins")
+	if err != nil {
+		return err
+	}
+	if config.Templates != "" {
+		templates = os.DirFS(config.Templates)
+	}
+	names, err := fs.Glob(templates, "*.ans")
+	if err != nil {
+		return err
+	}
+
+	values := bulletinValues(state, history, systemName)
+	for _, name := range names {
+		content, err := fs.ReadFile(templates, name)
+		if err != nil {
+			return err
+		}
+		// Color codes are translated before the values go in, which have
+		// theirs removed
+		ansi := fillTemplate(TranslateColorCodes(TrimStringFromSauce(string(content))), values)
+		ascii := ansiSequencePattern.ReplaceAllString(ansi, "")
+
+		base := strings.TrimSuffix(name, ".ans")
+		title := strings.Title(base)
+		if err := writeArtFile(filepath.Join(config.Output, base+".ans"), ansi, title, StripColorCodes(systemName), sauceFileANSi); err != nil {
+			return err
+		}
+		if err := writeArtFile(filepath.Join(config.Output, base+".asc"), ascii, title, StripColorCodes(systemName), sauceFileASCII); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArtFile writes content with a SAUCE record, replacing the file in one
+// step so the BBS never displays a partly written bulletin.
+func writeArtFile(path, content, title, group string, fileType byte) error {
+	sauce := Sauce{
+		Title:    title,
+		Author:   "talisman-wfc",
+		Group:    group,
+		Date:     time.Now(),
+		FileSize: len(content),
+		DataType: sauceDataCharacter,
+		FileType: fileType,
+		Width:    80,
+		Lines:    strings.Count(content, "\n"),
+		Font:     "IBM VGA",
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append([]byte(content), sauce.Bytes()...), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
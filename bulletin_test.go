@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestColorCodesInNames(t *testing.T) {
+	const systemName = "|12Retro|07 @X1FBBS@X07 @0E@Zone"
+
+	// Bulletins show the name without its codes, in the template's colors
+	template := TranslateColorCodes("|14Welcome to {SYSTEM}") + " {USER1}"
+	filled := fillTemplate(template, map[string]string{"SYSTEM": systemName, "USER1": "|04bob"})
+	if want := TranslateColorCodes("|14Welcome to ") + "Retro BBS Zone bob"; filled != want {
+		t.Errorf("bulletin = %q, want %q", filled, want)
+	}
+
+	// So does the footer
+	themes, err := LoadThemes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	Output = &out
+	defer func() { Output = os.Stdout }()
+	screen := &Screen{Theme: themes[0], SystemName: systemName, Width: 80, Height: 25}
+	screen.drawFooter(Rect{X: 1, Y: 25})
+	footer := StripAnsi(out.String())
+	if !strings.Contains(footer, "System Name: Retro BBS Zone") || strings.ContainsAny(footer, "|@") {
+		t.Errorf("footer = %q, want the system name without color codes", footer)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Art taller than this is cut off, so a stray cursor move can't allocate
+	// an enormous buffer
+	maxCanvasRows = 1000
+
+	defaultAttrFG = 7
+)
+
+// Attr is the text attribute of a cell. Colors are ANSI color numbers 0-7.
+type Attr struct {
+	FG, BG int
+	Bold   bool
+	Blink  bool
+}
+
+// Cell is one character position on a Canvas.
+type Cell struct {
+	Char rune
+	Attr Attr
+	Set  bool // False for cells the art never drew
+}
+
+// Canvas is a virtual screen that ANSI art is rendered into, so cursor
+// movement and wrapping are interpreted the way a terminal of the art's width
+// would, before a region of it is copied onto the real screen.
+type Canvas struct {
+	Width     int
+	ICEColors bool // Blink selects a bright background
+
+	rows [][]Cell
+
+	// Interpreter state
+	col, row           int
+	savedCol, savedRow int
+	attr               Attr
+}
+
+// RenderAnsi interprets ANSI art, already decoded to UTF-8, onto a new
+// Canvas of the given width.
+func RenderAnsi(art string, width int, iceColors bool) *Canvas {
+	c := &Canvas{Width: width, ICEColors: iceColors, attr: Attr{FG: defaultAttrFG}}
+	for i := 0; i < len(art); {
+		if seq := ansiSequenceAt(art, i); seq != "" {
+			c.control(seq)
+			i += len(seq)
+			continue
+		}
+		if strings.HasPrefix(art[i:], "\x1b7") || strings.HasPrefix(art[i:], "\x1b8") {
+			c.control(art[i : i+2])
+			i += 2
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(art[i:])
+		i += size
+		switch r {
+		case '\r':
+			c.col = 0
+		case '\n':
+			// LF-only art expects a new line to start at the left edge
+			c.col = 0
+			c.row++
+		case '\t':
+			c.col = min((c.col/8+1)*8, c.Width-1)
+		case sauceEOF:
+			return c
+		case 0x1b:
+			// Unsupported escape, skip it
+		default:
+			c.put(r)
+		}
+	}
+	return c
+}
+
+// Height is the number of rows the art used.
+func (c *Canvas) Height() int {
+	return len(c.rows)
+}
+
+// put draws a character at the cursor and advances it. Like a terminal, the
+// wrap at the right edge waits for the next character, so a full width line
+// followed by CR/LF doesn't leave a blank line.
+func (c *Canvas) put(r rune) {
+	if c.col >= c.Width {
+		c.col = 0
+		c.row++
+	}
+	if c.row >= maxCanvasRows {
+		return
+	}
+	c.grow(c.row)
+	c.rows[c.row][c.col] = Cell{Char: r, Attr: c.attr, Set: true}
+	c.col++
+}
+
+// grow makes sure row exists.
+func (c *Canvas) grow(row int) {
+	for len(c.rows) <= row {
+		c.rows = append(c.rows, make([]Cell, c.Width))
+	}
+}
+
+// erase clears cells from col to end (exclusive) on a row.
+func (c *Canvas) erase(row, from, to int) {
+	if row >= len(c.rows) {
+		return
+	}
+	for col := max(0, from); col < min(to, c.Width); col++ {
+		c.rows[row][col] = Cell{Char: ' ', Attr: c.attr, Set: true}
+	}
+}
+
+// control applies an escape sequence.
+func (c *Canvas) control(seq string) {
+	switch seq {
+	case "\x1b7":
+		c.savedCol, c.savedRow = c.col, c.row
+		return
+	case "\x1b8":
+		c.col, c.row = c.savedCol, c.savedRow
+		return
+	}
+
+	final := seq[len(seq)-1]
+	paramText := strings.TrimPrefix(seq[:len(seq)-1], Esc)
+	if strings.HasPrefix(paramText, "?") {
+		return // Private modes, e.g. ?7h
+	}
+	var params []int
+	for _, p := range strings.Split(paramText, ";") {
+		n, _ := strconv.Atoi(p)
+		params = append(params, n)
+	}
+	param := func(i, def int) int {
+		if i < len(params) && params[i] > 0 {
+			return params[i]
+		}
+		return def
+	}
+
+	switch final {
+	case 'A':
+		c.row = max(0, c.row-param(0, 1))
+	case 'B':
+		c.row = min(maxCanvasRows, c.row+param(0, 1))
+	case 'C':
+		c.col = min(c.Width-1, c.col+param(0, 1))
+	case 'D':
+		c.col = max(0, c.col-param(0, 1))
+	case 'E':
+		c.row, c.col = min(maxCanvasRows, c.row+param(0, 1)), 0
+	case 'F':
+		c.row, c.col = max(0, c.row-param(0, 1)), 0
+	case 'G':
+		c.col = min(c.Width-1, param(0, 1)-1)
+	case 'H', 'f':
+		c.row = min(maxCanvasRows, param(0, 1)-1)
+		c.col = min(c.Width-1, param(1, 1)-1)
+	case 'J':
+		switch param(0, 0) {
+		case 0:
+			c.erase(c.row, c.col, c.Width)
+			for row := c.row + 1; row < len(c.rows); row++ {
+				c.erase(row, 0, c.Width)
+			}
+		case 1:
+			for row := 0; row < c.row; row++ {
+				c.erase(row, 0, c.Width)
+			}
+			c.erase(c.row, 0, c.col+1)
+		case 2:
+			// ANSI.SYS also homes the cursor
+			c.rows = nil
+			c.row, c.col = 0, 0
+		}
+	case 'K':
+		switch param(0, 0) {
+		case 0:
+			c.erase(c.row, c.col, c.Width)
+		case 1:
+			c.erase(c.row, 0, c.col+1)
+		case 2:
+			c.erase(c.row, 0, c.Width)
+		}
+	case 's':
+		c.savedCol, c.savedRow = c.col, c.row
+	case 'u':
+		c.col, c.row = c.savedCol, c.savedRow
+	case 'm':
+		c.sgr(params)
+	}
+}
+
+// sgr applies Select Graphic Rendition parameters to the current attribute.
+func (c *Canvas) sgr(params []int) {
+	for i := 0; i < len(params); i++ {
+		n := params[i]
+		switch {
+		case n == 0:
+			c.attr = Attr{FG: defaultAttrFG}
+		case n == 1:
+			c.attr.Bold = true
+		case n == 5:
+			c.attr.Blink = true
+		case n == 22:
+			c.attr.Bold = false
+		case n == 25:
+			c.attr.Blink = false
+		case n >= 30 && n <= 37:
+			c.attr.FG = n - 30
+		case n == 39:
+			c.attr.FG = defaultAttrFG
+		case n >= 40 && n <= 47:
+			c.attr.BG = n - 40
+		case n == 49:
+			c.attr.BG = 0
+		case n == 38 || n == 48:
+			// Extended colors aren't part of DOS art, skip their arguments
+			if i+1 < len(params) && params[i+1] == 5 {
+				i += 2
+			} else if i+1 < len(params) && params[i+1] == 2 {
+				i += 4
+			}
+		}
+	}
+}
+
+// sequence returns the SGR sequence that selects an attribute on a terminal.
+func (c *Canvas) sequence(attr Attr) string {
+	sgr := "0"
+	if attr.Bold {
+		sgr += ";1"
+	}
+	bg := 40 + attr.BG
+	if attr.Blink {
+		if c.ICEColors {
+			bg = 100 + attr.BG
+		} else {
+			sgr += ";5"
+		}
+	}
+	return Esc + sgr + fmt.Sprintf(";%d;%dm", 30+attr.FG, bg)
+}
+
+// Blit copies the region starting at column left and row top of the canvas,
+// width by height cells, onto the screen at x, y. Cells the art never drew
+// are painted as spaces in background, so they match the rest of the screen.
+func (c *Canvas) Blit(x, y, left, top, width, height int, background string) {
+	for i := 0; i < height; i++ {
+		var line strings.Builder
+		current := ""
+		for col := left; col < left+width; col++ {
+			cell := Cell{}
+			if row := top + i; row < len(c.rows) && col < c.Width {
+				cell = c.rows[row][col]
+			}
+
+			sequence := Reset + background
+			char := ' '
+			if cell.Set {
+				sequence = c.sequence(cell.Attr)
+				char = cell.Char
+			}
+			if sequence != current {
+				line.WriteString(sequence)
+				current = sequence
+			}
+			line.WriteRune(char)
+		}
+		MoveCursor(x, y+i)
+		fmt.Fprint(Output, line.String()+Reset)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Config holds the WFC's own settings from wfc.ini. The file and every
+// setting in it are optional.
+type Config struct {
+	Screen      ScreenConfig
+	Bulletins   BulletinConfig
+	Screensaver ScreensaverConfig
+	Intro       IntroConfig
+	Events      []ScheduledEvent
+	Alerts      AlertConfig
+	Hooks       HookConfig
+	Scripts     string // Directory of Lua scripts, none when empty
+	Parser      *Parser
+	Log         LogConfig
+	DNS         DNSConfig
+	Detect      DetectConfig
+	Bans        BanConfig
+	History     string // Caller history file, the history is kept in memory only when empty
+}
+
+// ScreenConfig controls how the WFC screen is drawn.
+type ScreenConfig struct {
+	Layout string // Layout file, built-in layout when empty
+	Theme  string // Name or file name of the starting theme
+	Themes string // Directory of extra theme files
+}
+
+// BulletinConfig controls the generated bulletin files.
+type BulletinConfig struct {
+	Enabled   bool
+	Interval  time.Duration
+	Templates string // Directory of .ans templates, built-in templates when empty
+	Output    string // Directory the bulletins are written to
+}
+
+// ScreensaverConfig controls the idle screensaver.
+type ScreensaverConfig struct {
+	Enabled  bool
+	Idle     time.Duration // Time without log events or key presses before it starts
+	Mode     string        // starfield, matrix or art
+	Art      string        // Art file, glob or directory for art mode, relative to gfiles
+	Interval time.Duration // How long each piece of art is shown
+}
+
+// LogConfig controls how talisman.log is read.
+type LogConfig struct {
+	// Rotated log file or glob, relative to the log directory, whose newest
+	// match is read first so callers from before the rotation aren't lost
+	Previous string
+}
+
+// IntroConfig controls the ANSI art played at modem speed before the WFC
+// screen is shown.
+type IntroConfig struct {
+	Baud    int           // Playback speed in bits per second, 0 for full speed
+	Startup string        // Art file, glob or directory played on startup, relative to gfiles
+	Connect string        // Art played when a caller connects
+	Hold    time.Duration // How long finished art stays on screen
+}
+
+// loadWFCConfig reads wfc.ini, falling back to defaults for anything missing.
+func loadWFCConfig(iniFilePath, talismanPath string) (*Config, error) {
+	// Shadows allow several "event" and pattern lines, and patterns may
+	// contain # or ;
+	cfg, err := ini.LoadSources(ini.LoadOptions{Loose: true, AllowShadows: true, IgnoreInlineComment: true}, iniFilePath)
+	if err != nil {
+		return nil, err
+	}
+	configDir := filepath.Dir(iniFilePath)
+
+	screen := cfg.Section("screen")
+	bulletins := cfg.Section("bulletins")
+	saver := cfg.Section("screensaver")
+	intro := cfg.Section("intro")
+	config := &Config{
+		Screen: ScreenConfig{
+			Layout: resolvePath(configDir, screen.Key("layout").String()),
+			Theme:  screen.Key("theme").MustString("talisman"),
+			Themes: resolvePath(configDir, screen.Key("themes").String()),
+		},
+		Bulletins: BulletinConfig{
+			Enabled:   bulletins.Key("enabled").MustBool(false),
+			Interval:  bulletins.Key("interval").MustDuration(5 * time.Minute),
+			Templates: resolvePath(configDir, bulletins.Key("templates").String()),
+			Output:    resolvePath(talismanPath, bulletins.Key("output").MustString("gfiles")),
+		},
+		Screensaver: ScreensaverConfig{
+			Enabled:  saver.Key("enabled").MustBool(false),
+			Idle:     saver.Key("idle").MustDuration(10 * time.Minute),
+			Mode:     saver.Key("mode").In(saverStarfield, []string{saverStarfield, saverMatrix, saverArt}),
+			Art:      saver.Key("art").MustString("*.ans"),
+			Interval: saver.Key("interval").MustDuration(30 * time.Second),
+		},
+		Intro: IntroConfig{
+			Baud:    intro.Key("baud").MustInt(9600),
+			Startup: intro.Key("startup").String(),
+			Connect: intro.Key("connect").String(),
+			Hold:    intro.Key("hold").MustDuration(3 * time.Second),
+		},
+	}
+
+	alerts := cfg.Section("alerts")
+	config.Alerts = AlertConfig{
+		Users:        alerts.Key("users").Strings(","),
+		SoundCommand: alerts.Key("sound command").String(),
+		Sound:        resolvePath(configDir, alerts.Key("sound").String()),
+		FlashTime:    alerts.Key("flash time").MustDuration(10 * time.Second),
+		QuietFrom:    -1,
+		QuietTo:      -1,
+	}
+	for key, target := range map[string]*[]string{
+		"connect":    &config.Alerts.Connect,
+		"login":      &config.Alerts.Login,
+		"newuser":    &config.Alerts.NewUser,
+		"watch":      &config.Alerts.Watch,
+		"suspicious": &config.Alerts.Suspicious,
+	} {
+		if *target, err = parseAlertActions(alerts.Key(key).String()); err != nil {
+			return nil, fmt.Errorf("[alerts] %s: %w", key, err)
+		}
+	}
+	if baud := config.Intro.Baud; baud != 0 && (baud < 300 || baud > 115200) {
+		return nil, fmt.Errorf("[intro] baud: %d is not 0 or 300 to 115200", baud)
+	}
+	if quiet := alerts.Key("quiet").String(); quiet != "" {
+		from, to, ok := parseTimeRange(quiet)
+		if !ok {
+			return nil, fmt.Errorf("[alerts] quiet: invalid time range %q", quiet)
+		}
+		config.Alerts.QuietFrom, config.Alerts.QuietTo = from, to
+	}
+
+	config.Log.Previous = cfg.Section("log").Key("previous").String()
+	// Unlike a missing file setting, an empty one turns the history file off
+	config.History = "wfc-history.jsonl"
+	if history := cfg.Section("history"); history.HasKey("file") {
+		config.History = history.Key("file").String()
+	}
+	config.History = resolvePath(talismanPath, config.History)
+	config.Scripts = resolvePath(configDir, cfg.Section("scripts").Key("dir").String())
+
+	dns := cfg.Section("dns")
+	config.DNS = DNSConfig{
+		Show:       dns.Key("show").In(showIP, []string{showIP, showHostname, showBoth}),
+		TTL:        dns.Key("ttl").MustDuration(time.Hour),
+		Timeout:    dns.Key("timeout").MustDuration(2 * time.Second),
+		MaxRunning: dns.Key("max running").MustInt(4),
+	}
+
+	detect := cfg.Section("detect")
+	config.Detect = DetectConfig{
+		Window:     detect.Key("window").MustDuration(10 * time.Minute),
+		Connects:   detect.Key("connects").MustInt(10),
+		Unanswered: detect.Key("unanswered").MustInt(5),
+		BotTime:    detect.Key("bot time").MustDuration(15 * time.Second),
+	}
+
+	bans := cfg.Section("bans")
+	config.Bans = BanConfig{
+		Auto:     bans.Key("auto").MustBool(false),
+		Duration: bans.Key("duration").MustDuration(24 * time.Hour),
+		File:     resolvePath(talismanPath, bans.Key("file").String()),
+		Format:   bans.Key("format").In(banFormatTalisman, []string{banFormatTalisman, banFormatHostsDeny, banFormatNftables}),
+		NftSet:   bans.Key("nft set").MustString("inet filter wfc_banned"),
+		NftSet6:  bans.Key("nft set6").String(),
+		Command:  bans.Key("command").String(),
+		Audit:    resolvePath(talismanPath, bans.Key("audit").MustString("wfc-bans.jsonl")),
+	}
+
+	hooks := cfg.Section("hooks")
+	config.Hooks = HookConfig{
+		Commands:   make(map[string]string),
+		Timeout:    hooks.Key("timeout").MustDuration(30 * time.Second),
+		MaxRunning: hooks.Key("max running").MustInt(4),
+	}
+	for _, name := range hookEvents {
+		config.Hooks.Commands[name] = hooks.Key(name).String()
+	}
+
+	// Extra log patterns, several per event allowed, and location labels
+	config.Parser = &Parser{Labels: make(map[string]string)}
+	for _, key := range cfg.Section("patterns").Keys() {
+		for _, value := range key.ValueWithShadows() {
+			pattern, err := NewLogPattern(key.Name(), value)
+			if err != nil {
+				return nil, fmt.Errorf("[patterns] %s: %w", key.Name(), err)
+			}
+			config.Parser.Patterns = append(config.Parser.Patterns, pattern)
+		}
+	}
+	for _, key := range cfg.Section("labels").Keys() {
+		config.Parser.Labels[strings.ToLower(key.Name())] = key.String()
+	}
+	geoip := cfg.Section("geoip")
+	if config.Parser.GeoIP, err = OpenGeoIP(
+		resolvePath(configDir, geoip.Key("city").String()),
+		resolvePath(configDir, geoip.Key("asn").String()),
+	); err != nil {
+		return nil, fmt.Errorf("[geoip] %w", err)
+	}
+
+	events := cfg.Section("events")
+	if events.HasKey("event") {
+		for _, entry := range events.Key("event").ValueWithShadows() {
+			event, err := parseScheduledEvent(entry)
+			if err != nil {
+				return nil, fmt.Errorf("[events]: %w", err)
+			}
+			config.Events = append(config.Events, event)
+		}
+	}
+	return config, nil
+}
+
+// resolvePath makes a relative path from the config relative to base.
+func resolvePath(base, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Column widths of the node details view. The network gets what's left.
+const (
+	detailSinceWidth = 7
+	detailIPWidth    = 24
+	detailGeoWidth   = 18
+)
+
+// drawDetailScreen shows everything known about the caller on each node in
+// place of the WFC screen: when they connected, from which IP or hostname and
+// where that IP is.
+func (s *Screen) drawDetailScreen() {
+	MoveCursor(1, 1)
+	PrintSpaces(s.Width, s.Theme.Footer)
+	MoveCursor(1, 1)
+	fmt.Fprint(Output, s.Theme.Footer+s.Theme.FooterLabel+" Node Details"+Reset)
+
+	networkWidth := max(0, s.Width-1-nodeColWidth-userColWidth-detailSinceWidth-detailIPWidth-detailGeoWidth)
+	MoveCursor(1, 3)
+	fmt.Fprint(Output,
+		s.Theme.Background+" "+
+			formatCell("Node", nodeColWidth, s.Theme.NodeLabel)+
+			formatCell("User", userColWidth, s.Theme.UserLabel)+
+			formatCell("Since", detailSinceWidth, s.Theme.LocationLabel)+
+			formatCell("Address", detailIPWidth, s.Theme.LocationLabel)+
+			formatCell("From", detailGeoWidth, s.Theme.LocationLabel)+
+			formatCell("Network", networkWidth, s.Theme.LocationLabel),
+	)
+
+	// Leave room for the footer
+	rows := min(s.State.MaxNodes, s.Height-4)
+	for nodeNum := 1; nodeNum <= rows; nodeNum++ {
+		status := s.State.Node(nodeNum)
+		userColor := s.Theme.User
+		since := ""
+		if status.User == waitingUser {
+			userColor = s.Theme.UserWaiting
+		} else if !status.Since.IsZero() {
+			since = status.Since.Format("15:04")
+		}
+		MoveCursor(1, 3+nodeNum)
+		fmt.Fprint(Output,
+			s.Theme.Background+" "+
+				formatCell(strconv.Itoa(nodeNum), nodeColWidth, s.Theme.Node)+
+				formatCell(status.User, userColWidth, userColor)+
+				formatCell(since, detailSinceWidth, s.Theme.Location)+
+				formatCell(s.address(status.IP), detailIPWidth, s.Theme.Location)+
+				formatCell(status.Geo, detailGeoWidth, s.Theme.Location)+
+				formatCell(status.Network, networkWidth, s.Theme.Location),
+		)
+	}
+}
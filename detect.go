@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Reasons an IP is flagged
+const (
+	reasonFlood      = "flood"       // Too many connections
+	reasonBruteForce = "brute force" // Too many connections that never logged in
+)
+
+// DetectConfig sets when an IP's connections count as suspicious.
+type DetectConfig struct {
+	Window     time.Duration // Connections older than this are forgotten
+	Connects   int           // Connections in the window that make a flood, 0 for no limit
+	Unanswered int           // Connections without a login that make brute forcing, 0 for no limit
+	BotTime    time.Duration // Connections dropped sooner without logging in are bots
+}
+
+// Suspect is an IP flagged for suspicious activity.
+type Suspect struct {
+	IP         string
+	Reason     string
+	Node       string // Node of the latest connection
+	Connects   int    // Connections in the window
+	Logins     int    // Logins in the window
+	Unanswered int    // Connections in the window that ended without a login
+	Last       time.Time
+}
+
+// ipActivity is what's been seen of one IP within the window.
+type ipActivity struct {
+	connects   []time.Time
+	logins     []time.Time
+	unanswered []time.Time
+	node       string
+	flagged    bool
+	reported   bool // Returned by Apply; flagged while replaying, it isn't yet
+}
+
+// nodeCall is the connection in progress on a node.
+type nodeCall struct {
+	ip       string
+	loggedIn bool
+}
+
+// Detector watches connections (connectionPattern) and logins
+// (loginPattern) per IP and flags IPs that connect too often, or keep
+// connecting without logging in, as bots hammering the BBS do.
+type Detector struct {
+	config DetectConfig
+	ips    map[string]*ipActivity
+	nodes  map[string]nodeCall
+}
+
+// NewDetector creates a detector that has seen nothing yet.
+func NewDetector(config DetectConfig) *Detector {
+	return &Detector{config: config, ips: make(map[string]*ipActivity), nodes: make(map[string]nodeCall)}
+}
+
+// Apply counts an event against the caller's IP. It returns the IP as a
+// suspect when the event is the one that got it flagged. live is false while
+// the log is read back on startup: IPs flagged then are returned on their
+// first live connection or hangup instead, so a flood under way across a
+// restart is still reported.
+func (d *Detector) Apply(ev Event, live bool) (Suspect, bool) {
+	switch ev.Type {
+	case EventConnect:
+		ip := parseIP(ev.IP)
+		if ip == nil {
+			return Suspect{}, false
+		}
+		d.hangUp(ev.Node, ev.Time)
+		// Without the port, which may be logged and changes every call
+		d.nodes[ev.Node] = nodeCall{ip: ip.String()}
+		activity := d.activity(ip.String())
+		activity.connects = append(activity.connects, ev.Time)
+		activity.node = ev.Node
+		return d.check(ip.String(), ev.Time, live)
+	case EventLogin:
+		call, exists := d.nodes[ev.Node]
+		if !exists || call.loggedIn {
+			return Suspect{}, false
+		}
+		call.loggedIn = true
+		d.nodes[ev.Node] = call
+		activity := d.activity(call.ip)
+		activity.logins = append(activity.logins, ev.Time)
+	case EventLogoff:
+		if ip := d.hangUp(ev.Node, ev.Time); ip != "" {
+			return d.check(ip, ev.Time, live)
+		}
+	}
+	return Suspect{}, false
+}
+
+// hangUp ends the call on a node, counting it as unanswered when the caller
+// never logged in, and returns the IP when it was.
+func (d *Detector) hangUp(node string, t time.Time) string {
+	call, exists := d.nodes[node]
+	if !exists {
+		return ""
+	}
+	delete(d.nodes, node)
+	if call.loggedIn {
+		return ""
+	}
+	activity := d.activity(call.ip)
+	activity.unanswered = append(activity.unanswered, t)
+	return call.ip
+}
+
+// activity returns the record of an IP, creating it if needed.
+func (d *Detector) activity(ip string) *ipActivity {
+	if d.ips[ip] == nil {
+		d.ips[ip] = &ipActivity{}
+	}
+	return d.ips[ip]
+}
+
+// check forgets what's fallen out of the window and flags the IP when it's
+// over a limit, returning it when live and it wasn't reported already.
+func (d *Detector) check(ip string, now time.Time, live bool) (Suspect, bool) {
+	d.prune(now)
+	activity, exists := d.ips[ip]
+	if !exists || activity.reported {
+		return Suspect{}, false
+	}
+	suspect := d.suspect(ip, activity)
+	if suspect.Reason == "" {
+		return Suspect{}, false
+	}
+	activity.flagged = true
+	if !live {
+		return Suspect{}, false
+	}
+	activity.reported = true
+	return suspect, true
+}
+
+// prune drops activity older than the window, unflagging IPs that are back
+// under the limits and forgetting IPs with nothing left.
+func (d *Detector) prune(now time.Time) {
+	since := now.Add(-d.config.Window)
+	recent := func(times []time.Time) []time.Time {
+		i := sort.Search(len(times), func(i int) bool { return !times[i].Before(since) })
+		return times[i:]
+	}
+	for ip, activity := range d.ips {
+		activity.connects = recent(activity.connects)
+		activity.logins = recent(activity.logins)
+		activity.unanswered = recent(activity.unanswered)
+		if len(activity.connects)+len(activity.logins)+len(activity.unanswered) == 0 {
+			delete(d.ips, ip)
+		} else if activity.flagged && d.suspect(ip, activity).Reason == "" {
+			activity.flagged, activity.reported = false, false
+		}
+	}
+}
+
+// suspect describes an IP's activity, with a reason when it's over a limit.
+func (d *Detector) suspect(ip string, activity *ipActivity) Suspect {
+	suspect := Suspect{
+		IP:         ip,
+		Node:       activity.node,
+		Connects:   len(activity.connects),
+		Logins:     len(activity.logins),
+		Unanswered: len(activity.unanswered),
+	}
+	if len(activity.connects) > 0 {
+		suspect.Last = activity.connects[len(activity.connects)-1]
+	}
+	switch {
+	case d.config.Unanswered > 0 && suspect.Unanswered >= d.config.Unanswered:
+		suspect.Reason = reasonBruteForce
+	case d.config.Connects > 0 && suspect.Connects >= d.config.Connects:
+		suspect.Reason = reasonFlood
+	}
+	return suspect
+}
+
+// Suspects returns the IPs flagged within the window, latest first.
+func (d *Detector) Suspects(now time.Time) []Suspect {
+	d.prune(now)
+	var suspects []Suspect
+	for ip, activity := range d.ips {
+		if activity.flagged {
+			suspects = append(suspects, d.suspect(ip, activity))
+		}
+	}
+	sort.Slice(suspects, func(i, j int) bool { return suspects[i].Last.After(suspects[j].Last) })
+	return suspects
+}
+
+// String describes a suspect for notices.
+func (s Suspect) String() string {
+	return s.IP + " " + s.Why()
+}
+
+// Why gives the reason a suspect was flagged, with the counts behind it.
+func (s Suspect) Why() string {
+	return fmt.Sprintf("%s: %d connections, %d logins", s.Reason, s.Connects, s.Logins)
+}
+
+// drawSuspects lists the IPs flagged for suspicious activity.
+func (s *Screen) drawSuspects(rect Rect) int {
+	width, height := rect.W, rect.H
+	if width == 0 {
+		width = 40
+	}
+	if height == 0 {
+		height = 4
+	}
+
+	MoveCursor(rect.X, rect.Y)
+	fmt.Fprint(Output, formatCell(" Suspicious Activity", width, s.Theme.LastUserLabel))
+	var suspects []Suspect
+	if s.Detector != nil {
+		suspects = s.Detector.Suspects(time.Now())
+	}
+	for i := 0; i < height-1; i++ {
+		line := ""
+		if i < len(suspects) {
+			suspect := suspects[i]
+			line = fmt.Sprintf(" %-15s %3d calls %s", PadOrTruncate(s.address(suspect.IP), 15), suspect.Connects, suspect.Reason)
+		} else if i == 0 {
+			line = " None"
+		}
+		MoveCursor(rect.X, rect.Y+1+i)
+		fmt.Fprint(Output, formatCell(line, width, s.Theme.LastUser))
+	}
+	return height
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectorReplay(t *testing.T) {
+	d := NewDetector(DetectConfig{Window: 10 * time.Minute, Connects: 3})
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	connect := func(minute int, live bool) (Suspect, bool) {
+		return d.Apply(Event{Type: EventConnect, Time: start.Add(time.Duration(minute) * time.Minute), Node: "1", IP: "203.0.113.7"}, live)
+	}
+
+	// Over the limit before the restart: shown, but not reported while replaying
+	for minute := 0; minute < 4; minute++ {
+		if _, flagged := connect(minute, false); flagged {
+			t.Fatalf("connection %d reported while replaying", minute+1)
+		}
+	}
+	if suspects := d.Suspects(start.Add(4 * time.Minute)); len(suspects) != 1 || suspects[0].Reason != reasonFlood {
+		t.Fatalf("suspects after the replay = %+v, want 203.0.113.7 for flooding", suspects)
+	}
+
+	// The first live connection reports it, once
+	suspect, flagged := connect(5, true)
+	if !flagged || suspect.IP != "203.0.113.7" || suspect.Connects != 5 {
+		t.Fatalf("first live connection = %+v, %v; want 203.0.113.7 with 5 connections", suspect, flagged)
+	}
+	if _, flagged := connect(6, true); flagged {
+		t.Error("reported again while still flooding")
+	}
+
+	// Once it's back under the limit, flooding again is reported again
+	if suspects := d.Suspects(start.Add(30 * time.Minute)); len(suspects) != 0 {
+		t.Fatalf("suspects after the window = %+v, want none", suspects)
+	}
+	for minute := 30; minute < 32; minute++ {
+		if _, flagged := connect(minute, true); flagged {
+			t.Fatalf("connection at minute %d reported under the limit", minute)
+		}
+	}
+	if _, flagged := connect(32, true); !flagged {
+		t.Error("new flood not reported")
+	}
+}
+
+func TestDetectorBruteForce(t *testing.T) {
+	d := NewDetector(DetectConfig{Window: 10 * time.Minute, Unanswered: 2})
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	at := func(second int) time.Time { return start.Add(time.Duration(second) * time.Second) }
+
+	// A caller who logs in doesn't count
+	d.Apply(Event{Type: EventConnect, Time: at(0), Node: "1", IP: "198.51.100.1"}, true)
+	d.Apply(Event{Type: EventLogin, Time: at(5), Node: "1", User: "Sysop"}, true)
+	if _, flagged := d.Apply(Event{Type: EventLogoff, Time: at(60), Node: "1"}, true); flagged {
+		t.Fatal("caller who logged in was flagged")
+	}
+
+	d.Apply(Event{Type: EventConnect, Time: at(70), Node: "2", IP: "203.0.113.7"}, true)
+	if _, flagged := d.Apply(Event{Type: EventLogoff, Time: at(75), Node: "2"}, true); flagged {
+		t.Fatal("flagged after one unanswered connection")
+	}
+	d.Apply(Event{Type: EventConnect, Time: at(80), Node: "2", IP: "203.0.113.7"}, true)
+	suspect, flagged := d.Apply(Event{Type: EventLogoff, Time: at(85), Node: "2"}, true)
+	if !flagged || suspect.Reason != reasonBruteForce || suspect.Unanswered != 2 {
+		t.Fatalf("second unanswered connection = %+v, %v; want brute force", suspect, flagged)
+	}
+}
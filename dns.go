@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How caller addresses are shown
+const (
+	showIP       = "ip"
+	showHostname = "hostname"
+	showBoth     = "both"
+)
+
+// Cached names are swept once there are more than this many
+const maxHostnames = 1024
+
+// DNSConfig controls reverse DNS lookups of caller IPs.
+type DNSConfig struct {
+	Show       string        // ip, hostname or both; no lookups for ip
+	TTL        time.Duration // How long names, and failed lookups, are cached
+	Timeout    time.Duration // Longest a single lookup may take
+	MaxRunning int           // Lookups in flight at once; the rest queue
+}
+
+// Resolver looks up the names of an IP. net.DefaultResolver is one; a stand
+// in can answer from a table.
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+type hostname struct {
+	name    string // Empty when the lookup failed
+	expires time.Time
+}
+
+// Hostnames resolves caller IPs to names in the background and caches the
+// answers. Lookups never block the caller: Lookup answers from the cache and
+// starts a lookup when the cache has nothing current, and the IP is sent on
+// Resolved once the answer is in.
+type Hostnames struct {
+	config   DNSConfig
+	resolver Resolver
+	resolved chan string
+	slots    chan struct{}
+
+	mu      sync.Mutex
+	names   map[string]hostname
+	pending map[string]bool
+}
+
+// NewHostnames creates the cache, or returns nil when only IPs are shown.
+func NewHostnames(config DNSConfig, resolver Resolver) *Hostnames {
+	if config.Show == showIP {
+		return nil
+	}
+	return &Hostnames{
+		config:   config,
+		resolver: resolver,
+		resolved: make(chan string, 16),
+		slots:    make(chan struct{}, max(1, config.MaxRunning)),
+		names:    make(map[string]hostname),
+		pending:  make(map[string]bool),
+	}
+}
+
+// Lookup returns the cached name of an IP, which may be stale, and starts a
+// lookup when it's missing or expired.
+func (h *Hostnames) Lookup(address string) string {
+	if h == nil {
+		return ""
+	}
+	ip := parseIP(address)
+	if ip == nil {
+		return ""
+	}
+	key := ip.String()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cached, found := h.names[key]
+	if (!found || time.Now().After(cached.expires)) && !h.pending[key] {
+		h.pending[key] = true
+		go h.resolve(key)
+	}
+	return cached.name
+}
+
+// Resolved delivers each IP whose lookup has finished, for the WFC to redraw
+// the nodes it's on. It's nil when only IPs are shown.
+func (h *Hostnames) Resolved() <-chan string {
+	if h == nil {
+		return nil
+	}
+	return h.resolved
+}
+
+// resolve looks an IP up once a slot is free and caches the answer.
+func (h *Hostnames) resolve(ip string) {
+	h.slots <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.Timeout)
+	names, err := h.resolver.LookupAddr(ctx, ip)
+	cancel()
+	<-h.slots
+
+	name := ""
+	if err == nil && len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+	now := time.Now()
+	h.mu.Lock()
+	if len(h.names) >= maxHostnames {
+		for key, cached := range h.names {
+			if now.After(cached.expires) {
+				delete(h.names, key)
+			}
+		}
+	}
+	h.names[ip] = hostname{name: name, expires: now.Add(h.config.TTL)}
+	delete(h.pending, ip)
+	h.mu.Unlock()
+	h.resolved <- ip
+}
+
+// address formats a caller's IP for display as the ip, hostname or both
+// setting asks, falling back to the IP while there's no name.
+func (s *Screen) address(ip string) string {
+	name := s.Hostnames.Lookup(ip)
+	if name == "" {
+		return ip
+	}
+	switch s.Hostnames.config.Show {
+	case showHostname:
+		return name
+	case showBoth:
+		return name + " (" + ip + ")"
+	}
+	return ip
+}
+
+// sameIP reports whether two addresses as logged are the same IP.
+func sameIP(a, b string) bool {
+	ipA, ipB := parseIP(a), parseIP(b)
+	return ipA != nil && ipB != nil && ipA.Equal(ipB)
+}
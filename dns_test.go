@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers lookups from a table, counting them and how many run
+// at once. While hold is open, lookups wait for it to close or time out.
+type fakeResolver struct {
+	mu         sync.Mutex
+	names      map[string]string
+	hold       chan struct{}
+	calls      map[string]int
+	running    int
+	maxRunning int
+}
+
+func newFakeResolver(names map[string]string) *fakeResolver {
+	return &fakeResolver{names: names, calls: make(map[string]int)}
+}
+
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	r.mu.Lock()
+	r.calls[addr]++
+	r.running++
+	r.maxRunning = max(r.maxRunning, r.running)
+	name, found := r.names[addr]
+	hold := r.hold
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running--
+		r.mu.Unlock()
+	}()
+
+	if hold != nil {
+		select {
+		case <-hold:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if !found {
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+	return []string{name + "."}, nil
+}
+
+func (r *fakeResolver) callsFor(addr string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[addr]
+}
+
+// waitResolved waits for the next finished lookup and returns its IP.
+func waitResolved(t *testing.T, h *Hostnames) string {
+	t.Helper()
+	select {
+	case ip := <-h.Resolved():
+		return ip
+	case <-time.After(time.Second):
+		t.Fatal("lookup never finished")
+		return ""
+	}
+}
+
+func testDNSConfig() DNSConfig {
+	return DNSConfig{Show: showBoth, TTL: time.Hour, Timeout: time.Second, MaxRunning: 4}
+}
+
+func TestHostnamesLookup(t *testing.T) {
+	resolver := newFakeResolver(map[string]string{"203.0.113.7": "host.example.com"})
+	h := NewHostnames(testDNSConfig(), resolver)
+
+	// The port some logs include is ignored
+	if name := h.Lookup("203.0.113.7:51234"); name != "" {
+		t.Fatalf("first lookup = %q, want nothing until it's resolved", name)
+	}
+	if ip := waitResolved(t, h); ip != "203.0.113.7" {
+		t.Fatalf("resolved %q, want 203.0.113.7", ip)
+	}
+	if name := h.Lookup("203.0.113.7"); name != "host.example.com" {
+		t.Fatalf("lookup = %q, want host.example.com", name)
+	}
+	if calls := resolver.callsFor("203.0.113.7"); calls != 1 {
+		t.Errorf("resolver called %d times, want 1", calls)
+	}
+}
+
+func TestHostnamesNotShown(t *testing.T) {
+	config := testDNSConfig()
+	config.Show = showIP
+	h := NewHostnames(config, newFakeResolver(nil))
+	if h != nil {
+		t.Fatal("hostnames created when only IPs are shown")
+	}
+	if name := h.Lookup("203.0.113.7"); name != "" {
+		t.Errorf("lookup = %q, want nothing", name)
+	}
+	if h.Resolved() != nil {
+		t.Error("resolved channel isn't nil")
+	}
+}
+
+func TestHostnamesTTL(t *testing.T) {
+	resolver := newFakeResolver(map[string]string{"203.0.113.7": "old.example.com"})
+	config := testDNSConfig()
+	config.TTL = 50 * time.Millisecond
+	h := NewHostnames(config, resolver)
+
+	h.Lookup("203.0.113.7")
+	waitResolved(t, h)
+	resolver.mu.Lock()
+	resolver.names["203.0.113.7"] = "new.example.com"
+	resolver.mu.Unlock()
+	if name := h.Lookup("203.0.113.7"); name != "old.example.com" {
+		t.Fatalf("lookup within the TTL = %q, want old.example.com", name)
+	}
+
+	time.Sleep(2 * config.TTL)
+	// The expired name is still shown while it's looked up again
+	if name := h.Lookup("203.0.113.7"); name != "old.example.com" {
+		t.Fatalf("lookup after the TTL = %q, want old.example.com", name)
+	}
+	waitResolved(t, h)
+	if name := h.Lookup("203.0.113.7"); name != "new.example.com" {
+		t.Fatalf("lookup after the new answer = %q, want new.example.com", name)
+	}
+	if calls := resolver.callsFor("203.0.113.7"); calls != 2 {
+		t.Errorf("resolver called %d times, want 2", calls)
+	}
+}
+
+func TestHostnamesTimeout(t *testing.T) {
+	resolver := newFakeResolver(map[string]string{"203.0.113.7": "slow.example.com"})
+	resolver.hold = make(chan struct{}) // Never answers
+	config := testDNSConfig()
+	config.Timeout = 20 * time.Millisecond
+	h := NewHostnames(config, resolver)
+
+	start := time.Now()
+	h.Lookup("203.0.113.7")
+	waitResolved(t, h)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("lookup took %v, want about %v", elapsed, config.Timeout)
+	}
+	if name := h.Lookup("203.0.113.7"); name != "" {
+		t.Errorf("lookup = %q, want nothing after a timeout", name)
+	}
+}
+
+func TestHostnamesFailureCached(t *testing.T) {
+	resolver := newFakeResolver(nil)
+	h := NewHostnames(testDNSConfig(), resolver)
+
+	h.Lookup("198.51.100.1")
+	waitResolved(t, h)
+	for i := 0; i < 3; i++ {
+		if name := h.Lookup("198.51.100.1"); name != "" {
+			t.Fatalf("lookup = %q, want nothing", name)
+		}
+	}
+	select {
+	case ip := <-h.Resolved():
+		t.Fatalf("%s looked up again within the TTL", ip)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if calls := resolver.callsFor("198.51.100.1"); calls != 1 {
+		t.Errorf("resolver called %d times, want 1", calls)
+	}
+}
+
+func TestHostnamesMaxRunning(t *testing.T) {
+	resolver := newFakeResolver(nil)
+	resolver.hold = make(chan struct{})
+	config := testDNSConfig()
+	config.MaxRunning = 2
+	h := NewHostnames(config, resolver)
+
+	ips := []string{"198.51.100.1", "198.51.100.2", "198.51.100.3", "198.51.100.4", "198.51.100.5"}
+	for _, ip := range ips {
+		h.Lookup(ip)
+		// Asking again while it's pending doesn't start another lookup
+		h.Lookup(ip)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(resolver.hold)
+	for range ips {
+		waitResolved(t, h)
+	}
+
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+	if resolver.maxRunning != config.MaxRunning {
+		t.Errorf("%d lookups ran at once, want %d", resolver.maxRunning, config.MaxRunning)
+	}
+	for _, ip := range ips {
+		if resolver.calls[ip] != 1 {
+			t.Errorf("%s looked up %d times, want 1", ip, resolver.calls[ip])
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// DropFile holds the fields we use from a DOOR32.SYS or DOOR.SYS drop file.
+type DropFile struct {
+	CommType int // 0 = local, 1 = serial, 2 = telnet socket
+	Handle   int // Socket or comm handle passed by the BBS
+	Baud     int
+	UserName string
+	Node     int
+	TimeLeft int // Minutes
+	ANSI     bool
+}
+
+// ReadDropFile loads a drop file, picking the format from the file name.
+func ReadDropFile(path string) (*DropFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Base(path), "door32.sys") {
+		return parseDoor32(lines)
+	}
+	return parseDoorSys(lines)
+}
+
+// parseDoor32 reads the 11 line DOOR32.SYS format.
+func parseDoor32(lines []string) (*DropFile, error) {
+	if len(lines) < 11 {
+		return nil, fmt.Errorf("DOOR32.SYS has %d lines, expected 11", len(lines))
+	}
+	drop := &DropFile{
+		CommType: atoiOrZero(lines[0]),
+		Handle:   atoiOrZero(lines[1]),
+		Baud:     atoiOrZero(lines[2]),
+		UserName: lines[6], // Handle/alias
+		TimeLeft: atoiOrZero(lines[8]),
+		ANSI:     atoiOrZero(lines[9]) > 0,
+		Node:     atoiOrZero(lines[10]),
+	}
+	if drop.UserName == "" {
+		drop.UserName = lines[5] // Real name
+	}
+	return drop, nil
+}
+
+// parseDoorSys reads the classic 52 line DOOR.SYS format. Only the first 20
+// lines are needed.
+func parseDoorSys(lines []string) (*DropFile, error) {
+	if len(lines) < 20 {
+		return nil, fmt.Errorf("DOOR.SYS has %d lines, expected at least 20", len(lines))
+	}
+	drop := &DropFile{
+		Baud:     atoiOrZero(lines[1]),
+		Node:     atoiOrZero(lines[3]),
+		UserName: lines[9],
+		TimeLeft: atoiOrZero(lines[18]),
+		ANSI:     strings.EqualFold(lines[19], "GR"),
+	}
+	// "COM0:" means a local session
+	if strings.TrimSuffix(strings.ToUpper(lines[0]), ":") != "COM0" {
+		drop.CommType = 1
+	}
+	return drop, nil
+}
+
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
+
+// callerConn returns the connection to draw on, using the socket handle from
+// the drop file when it's usable and stdio otherwise.
+func callerConn(drop *DropFile) (io.Reader, io.Writer) {
+	if drop.CommType == 2 && drop.Handle > 0 {
+		socket := os.NewFile(uintptr(drop.Handle), "socket")
+		if _, err := socket.Stat(); err == nil {
+			return socket, socket
+		}
+	}
+	return os.Stdin, os.Stdout
+}
+
+// runDoor shows a read-only Who's Online screen to the caller described by
+// the drop file, then waits for a key and exits.
+func runDoor(dropFilePath, talismanPath, logFilePath string, maxNodes int, systemName string, layout *Layout, theme *Theme, config *Config) {
+	drop, err := ReadDropFile(dropFilePath)
+	checkError(err, "reading drop file")
+
+	previous := previousLog(config.Log.Previous, logFilePath)
+	state := NewState(maxNodes, "None")
+	state.BotTime = config.Detect.BotTime
+	if previous != "" {
+		if _, err := state.Replay(previous, config.Parser, nil); err != nil {
+			log.Printf("Error reading rotated log %s: %v", previous, err)
+		}
+	}
+	_, err = state.Replay(logFilePath, config.Parser, nil)
+	checkError(err, "reading log file")
+	state.LastUser = findLastLoggedOffUser(previous, logFilePath, maxLogLines)
+
+	in, conn := callerConn(drop)
+	if in == os.Stdin && term.IsTerminal(int(os.Stdin.Fd())) {
+		oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+		checkError(err, "Error entering raw mode")
+		defer term.Restore(int(os.Stdin.Fd()), oldState)
+	}
+
+	// The screen is drawn in UTF-8 like the local WFC and encoded to CP437
+	// on the way out, which round-trips the header art unchanged
+	Output = encoding.ReplaceUnsupported(charmap.CodePage437.NewEncoder()).Writer(conn)
+	defer func() { Output = os.Stdout }()
+
+	screen := &Screen{
+		Layout:     layout,
+		Theme:      theme,
+		State:      state,
+		Height:     24, // Drop files don't carry the screen size, assume a standard terminal
+		Width:      80,
+		SystemName: systemName,
+		ArtDir:     filepath.Join(talismanPath, "gfiles"),
+		Hint:       "Press any key",
+		Events:     config.Events,
+		ShowGeo:    config.Parser.GeoIP != nil,
+	}
+	screen.Draw()
+
+	b := make([]byte, 1)
+	in.Read(b)
+
+	fmt.Fprint(Output, Reset)
+	ClearScreen()
+}
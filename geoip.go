@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP looks caller IPs up in local MaxMind or DB-IP .mmdb databases, so no
+// lookup ever leaves the machine.
+type GeoIP struct {
+	city *maxminddb.Reader // City or country database
+	asn  *maxminddb.Reader // ASN database
+}
+
+// geoRecord holds the fields used from city, country and ASN databases,
+// which MaxMind and DB-IP lay out the same way.
+type geoRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	ASNumber       uint   `maxminddb:"autonomous_system_number"`
+	ASOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// OpenGeoIP opens the city and ASN databases. Either may be empty, and with
+// neither there's no GeoIP and every lookup comes back empty.
+func OpenGeoIP(cityPath, asnPath string) (*GeoIP, error) {
+	if cityPath == "" && asnPath == "" {
+		return nil, nil
+	}
+	g := &GeoIP{}
+	var err error
+	if cityPath != "" {
+		if g.city, err = maxminddb.Open(cityPath); err != nil {
+			return nil, fmt.Errorf("city: %w", err)
+		}
+	}
+	if asnPath != "" {
+		if g.asn, err = maxminddb.Open(asnPath); err != nil {
+			return nil, fmt.Errorf("asn: %w", err)
+		}
+	}
+	return g, nil
+}
+
+// Lookup returns where an IP is, like "Toronto, ON, CA", and the network it
+// belongs to, like "AS13335 Cloudflare, Inc.". Either is empty when the IP
+// isn't in the databases, as for private addresses.
+func (g *GeoIP) Lookup(address string) (place, network string) {
+	if g == nil {
+		return "", ""
+	}
+	ip := parseIP(address)
+	if ip == nil {
+		return "", ""
+	}
+
+	var record geoRecord
+	if g.city != nil && g.city.Lookup(ip, &record) == nil {
+		var parts []string
+		if city := record.City.Names["en"]; city != "" {
+			parts = append(parts, city)
+			if len(record.Subdivisions) > 0 && record.Subdivisions[0].ISOCode != "" {
+				parts = append(parts, record.Subdivisions[0].ISOCode)
+			}
+			parts = append(parts, record.Country.ISOCode)
+		} else if country := record.Country.Names["en"]; country != "" {
+			parts = append(parts, country)
+		}
+		place = strings.Join(parts, ", ")
+	}
+
+	record = geoRecord{}
+	if g.asn != nil && g.asn.Lookup(ip, &record) == nil && record.ASNumber != 0 {
+		network = strings.TrimSpace(fmt.Sprintf("AS%d %s", record.ASNumber, record.ASOrganization))
+	}
+	return place, network
+}
+
+// parseIP reads an IP as logged, which may carry a port.
+func parseIP(address string) net.IP {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	return net.ParseIP(strings.Trim(address, "[]"))
+}
@@ -4,7 +4,11 @@ go 1.23.0
 
 require (
 	github.com/hpcloud/tail v1.0.0
+	github.com/oschwald/maxminddb-golang v1.13.1
 	github.com/rivo/tview v0.0.0-20240818110301-fd649dbf1223
+	github.com/yuin/gopher-lua v1.1.2
+	golang.org/x/term v0.17.0
+	golang.org/x/text v0.14.0
 	gopkg.in/ini.v1 v1.67.0
 )
 
@@ -28,8 +32,6 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.24.0 // indirect
-	golang.org/x/term v0.17.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 	gopkg.in/fsnotify.v1 v1.4.7 // indirect
 	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
 )
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Session is one call, from connection to logoff, as kept in the caller
+// history.
+type Session struct {
+	Node    string    `json:"node"`
+	User    string    `json:"user,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	Geo     string    `json:"geo,omitempty"`     // Where IP is, when a GeoIP database was set up
+	Network string    `json:"network,omitempty"` // Autonomous system IP belongs to
+	Start   time.Time `json:"start"`             // Connection, or login when the connection wasn't logged
+	Login   time.Time `json:"login"`             // Zero when the caller never logged in
+	End     time.Time `json:"end"`
+	NewUser bool      `json:"newuser,omitempty"`
+	Doors   []string  `json:"doors,omitempty"` // Doors run, in order
+	Posts   int       `json:"posts,omitempty"`
+
+	BadPasswords int `json:"badpasswords,omitempty"`
+}
+
+// key identifies a session, so the same call read from several logs, or
+// from the same log on every start, is only stored once.
+func (s Session) key() string {
+	return s.Node + "|" + s.Start.UTC().Format(time.RFC3339) + "|" + s.User
+}
+
+// Connection classes
+const (
+	classHuman     = "human"            // Logged in or signed up
+	classBot       = "bot"              // Dropped before the login prompt
+	classFailed    = "failed login"     // Got the login prompt but never logged in
+	classAbandoned = "abandoned signup" // Started signing up but never logged in
+)
+
+// Class sorts a session into human, bot, failed login or abandoned signup by
+// whether and how soon the caller got past the login prompt.
+func (s Session) Class(botTime time.Duration) string {
+	switch {
+	case !s.Login.IsZero():
+		return classHuman
+	case s.NewUser:
+		return classAbandoned
+	case s.Duration() < botTime:
+		return classBot
+	}
+	return classFailed
+}
+
+// Duration is how long the caller was connected.
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// History is the caller history store: every finished session, oldest
+// first, kept in memory and appended to a JSON lines file when it has one.
+type History struct {
+	path     string
+	sessions []Session
+	seen     map[string]bool
+}
+
+// LoadHistory reads the history file, which is created on the first new
+// session. Lines that can't be read are skipped. An empty path keeps the
+// history in memory only.
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path, seen: make(map[string]bool)}
+	if path == "" {
+		return h, nil
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var session Session
+		if err := json.Unmarshal(scanner.Bytes(), &session); err != nil {
+			// Such as a line left half written by a crash
+			log.Printf("Skipping %s line %d: %v", path, line, err)
+			continue
+		}
+		h.insert(session)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// insert adds a session in start order, unless it's already known.
+func (h *History) insert(session Session) bool {
+	if h.seen[session.key()] {
+		return false
+	}
+	h.seen[session.key()] = true
+	i := sort.Search(len(h.sessions), func(i int) bool { return h.sessions[i].Start.After(session.Start) })
+	h.sessions = append(h.sessions, Session{})
+	copy(h.sessions[i+1:], h.sessions[i:])
+	h.sessions[i] = session
+	return true
+}
+
+// Add stores finished sessions that aren't in the history yet and returns
+// how many were new.
+func (h *History) Add(sessions ...Session) (int, error) {
+	var added []Session
+	for _, session := range sessions {
+		if h.insert(session) {
+			added = append(added, session)
+		}
+	}
+	if h.path == "" || len(added) == 0 {
+		return len(added), nil
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return len(added), err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	// Start on a new line after a line left half written by a crash
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			writer.WriteByte('\n')
+		}
+	}
+	encoder := json.NewEncoder(writer)
+	for _, session := range added {
+		if err := encoder.Encode(session); err != nil {
+			return len(added), err
+		}
+	}
+	return len(added), writer.Flush()
+}
+
+// Sessions returns every session, oldest first. The slice must not be changed.
+func (h *History) Sessions() []Session {
+	return h.sessions
+}
+
+// SessionTracker builds sessions from log events, node by node.
+type SessionTracker struct {
+	open map[string]*Session
+}
+
+// NewSessionTracker creates a tracker with no calls in progress.
+func NewSessionTracker() *SessionTracker {
+	return &SessionTracker{open: make(map[string]*Session)}
+}
+
+// Apply adds an event to the session on its node. It returns the session
+// the event finished: on logoff, or when a new connection arrives on a node
+// whose previous call was never logged off.
+func (t *SessionTracker) Apply(ev Event) (Session, bool) {
+	session := t.open[ev.Node]
+	switch ev.Type {
+	case EventConnect:
+		t.open[ev.Node] = &Session{Node: ev.Node, IP: ev.IP, Geo: ev.Geo, Network: ev.Network, Start: ev.Time}
+		if session != nil {
+			session.End = ev.Time
+			return *session, true
+		}
+	case EventLogin:
+		if session == nil || !session.Login.IsZero() {
+			// Connection not logged, or rotated away
+			session = &Session{Node: ev.Node, Start: ev.Time}
+			t.open[ev.Node] = session
+		}
+		session.User, session.Login = ev.User, ev.Time
+	case EventNewUser:
+		if session != nil {
+			session.NewUser = true
+		}
+	case EventMenu:
+		if session != nil && session.User == "" {
+			session.User = ev.User
+		}
+	case EventActivity:
+		if session == nil {
+			break
+		}
+		switch ev.Action {
+		case actionDoor:
+			session.Doors = append(session.Doors, ev.Location)
+		case actionPost:
+			session.Posts++
+		}
+	case EventFailedLogin:
+		if session != nil {
+			session.BadPasswords++
+		}
+	case EventLogoff:
+		if session != nil {
+			delete(t.open, ev.Node)
+			session.End = ev.Time
+			return *session, true
+		}
+	}
+	return Session{}, false
+}
+
+// ImportLogs reads every log next to logFilePath whose name starts like it,
+// oldest first, including rotated and gzipped ones, and adds the sessions
+// found to the history. It returns the number of logs read and of new
+// sessions.
+func ImportLogs(logFilePath string, parser *Parser, history *History) (int, int, error) {
+	dir := filepath.Dir(logFilePath)
+	prefix := strings.TrimSuffix(filepath.Base(logFilePath), ".log")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	type logEntry struct {
+		path     string
+		modified time.Time
+	}
+	var logs []logEntry
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) || !strings.Contains(entry.Name(), ".log") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return 0, 0, err
+		}
+		logs = append(logs, logEntry{filepath.Join(dir, entry.Name()), info.ModTime()})
+	}
+	// Rotated names don't sort by age reliably (talisman.log.2 is older than
+	// talisman.log.1), modification times do
+	sort.Slice(logs, func(i, j int) bool { return logs[i].modified.Before(logs[j].modified) })
+
+	// One tracker across every log, so calls spanning a rotation are joined
+	tracker := NewSessionTracker()
+	var sessions []Session
+	for _, logFile := range logs {
+		file, err := openLog(logFile.path)
+		if err != nil {
+			return 0, 0, err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if ev, ok := parser.Parse(scanner.Text()); ok {
+				if session, done := tracker.Apply(ev); done {
+					sessions = append(sessions, session)
+				}
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return 0, 0, fmt.Errorf("%s: %w", logFile.path, err)
+		}
+	}
+
+	added, err := history.Add(sessions...)
+	return len(logs), added, err
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Event names hooks can be configured for
+var hookEvents = []string{"connect", "login", "newuser", "logoff", "door", "post", "failedlogin"}
+
+// HookConfig holds the commands run for log events.
+type HookConfig struct {
+	Commands   map[string]string // Command line by event name
+	Timeout    time.Duration     // Hooks running longer are killed
+	MaxRunning int               // Hooks running at once, further events are skipped
+}
+
+// hookPayload is the event data a hook gets as JSON on stdin.
+type hookPayload struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Node     string    `json:"node,omitempty"`
+	User     string    `json:"user,omitempty"`
+	IP       string    `json:"ip,omitempty"`
+	Action   string    `json:"action,omitempty"`
+	Location string    `json:"location,omitempty"`
+	Label    string    `json:"label,omitempty"`
+}
+
+// Hooks runs external commands for log events in the background, so a slow
+// hook can't hold up the log tailer.
+type Hooks struct {
+	config HookConfig
+	slots  chan struct{}
+	errors chan string
+}
+
+// NewHooks creates the hook runner.
+func NewHooks(config HookConfig) *Hooks {
+	return &Hooks{config: config, slots: make(chan struct{}, max(1, config.MaxRunning)), errors: make(chan string, 16)}
+}
+
+// Errors delivers a message for each hook that failed or was skipped, for
+// the WFC to show in the footer rather than print over the screen.
+func (h *Hooks) Errors() <-chan string {
+	return h.errors
+}
+
+// report sends an error message, dropping it when the WFC is behind on them.
+func (h *Hooks) report(format string, args ...any) {
+	select {
+	case h.errors <- fmt.Sprintf(format, args...):
+	default:
+	}
+}
+
+// hookEventName returns the name hooks use for an event, or "" for events
+// that have no hook.
+func hookEventName(ev Event) string {
+	switch ev.Type {
+	case EventConnect:
+		return "connect"
+	case EventLogin:
+		return "login"
+	case EventNewUser:
+		return "newuser"
+	case EventLogoff:
+		return "logoff"
+	case EventFailedLogin:
+		return "failedlogin"
+	case EventActivity:
+		switch ev.Action {
+		case actionDoor:
+			return "door"
+		case actionPost:
+			return "post"
+		}
+	}
+	return ""
+}
+
+// Run starts the hook configured for an event, if any. The event is skipped
+// when the maximum number of hooks is already running.
+func (h *Hooks) Run(ev Event) {
+	name := hookEventName(ev)
+	command := h.config.Commands[name]
+	if command == "" {
+		return
+	}
+
+	select {
+	case h.slots <- struct{}{}:
+	default:
+		h.report("Skipping %s hook, %d hooks are still running", name, cap(h.slots))
+		return
+	}
+	go func() {
+		defer func() { <-h.slots }()
+		h.run(command, name, ev)
+	}()
+}
+
+func (h *Hooks) run(command, name string, ev Event) {
+	args := strings.Fields(command)
+	payload, err := json.Marshal(hookPayload{
+		Event:    name,
+		Time:     ev.Time,
+		Node:     ev.Node,
+		User:     ev.User,
+		IP:       ev.IP,
+		Action:   ev.Action,
+		Location: ev.Location,
+		Label:    ev.Label,
+	})
+	if err != nil {
+		h.report("Error encoding %s hook data: %v", name, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.config.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(),
+		"WFC_EVENT="+name,
+		"WFC_TIME="+ev.Time.Format(time.RFC3339),
+		"WFC_NODE="+ev.Node,
+		"WFC_USER="+ev.User,
+		"WFC_IP="+ev.IP,
+		"WFC_ACTION="+ev.Action,
+		"WFC_LOCATION="+ev.Location,
+		"WFC_LABEL="+ev.Label,
+	)
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			h.report("%s hook %q timed out after %v", name, command, h.config.Timeout)
+			return
+		}
+		h.report("Error running %s hook %q: %v", name, command, err)
+	}
+}
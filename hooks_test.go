@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookErrors(t *testing.T) {
+	hooks := NewHooks(HookConfig{Commands: map[string]string{"login": "false"}, Timeout: 5 * time.Second, MaxRunning: 1})
+	hooks.Run(Event{Type: EventLogin, Time: time.Now(), Node: "1", User: "bob"})
+	select {
+	case text := <-hooks.Errors():
+		if !strings.Contains(text, `login hook "false"`) {
+			t.Errorf("error = %q, want the failed login hook", text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed hook not reported")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// defaultLayout matches the classic WFC screen: header art, the node table
+// under it, last user and today's calls above the footer.
+const defaultLayout = `
+[art]
+row  = 1
+col  = 1
+file = wfc.ans
+
+[nodes]
+below = art
+
+[stats]
+row = -4
+
+[footer]
+row = -1
+`
+
+// Widget types that can be placed on the screen
+var widgetTypes = map[string]bool{
+	"art":         true,
+	"nodes":       true,
+	"lastcallers": true,
+	"stats":       true,
+	"clock":       true,
+	"system":      true,
+	"counters":    true,
+	"suspicious":  true,
+	"footer":      true,
+}
+
+// Layout is the list of widgets on the WFC screen, drawn in file order.
+type Layout struct {
+	Widgets []Widget
+}
+
+// Widget is one element of the layout. Its position and size are kept as
+// written so they can be resolved against the terminal size when drawn.
+type Widget struct {
+	Name   string
+	Type   string
+	Row    coord
+	Col    coord
+	Width  coord
+	Height coord
+	Below  string // Name of a widget this one is placed right under
+
+	// Art widgets
+	File     string // Art file, glob or directory, relative to gfiles
+	Mode     string // How files are picked from the pool: rotate or random
+	Interval time.Duration
+	Schedule []ArtRule
+}
+
+// Rect is a widget's resolved position on screen. W and H are 0 when the
+// widget should use its natural size.
+type Rect struct {
+	X, Y, W, H int
+}
+
+// coord is a row, column or size from the layout file: a plain number, a
+// negative number counting back from the bottom or right edge, or a percentage.
+type coord struct {
+	value   int
+	percent bool
+}
+
+func parseCoord(s string) (coord, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return coord{}, nil
+	}
+	c := coord{}
+	if strings.HasSuffix(s, "%") {
+		c.percent = true
+		s = strings.TrimSuffix(s, "%")
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return coord{}, fmt.Errorf("invalid position %q", s)
+	}
+	c.value = value
+	return c, nil
+}
+
+// position resolves a 1-based row or column on a screen dimension of size.
+func (c coord) position(size int) int {
+	switch {
+	case c.percent:
+		return 1 + size*c.value/100
+	case c.value < 0:
+		return size + 1 + c.value
+	case c.value == 0:
+		return 1
+	}
+	return c.value
+}
+
+// length resolves a width or height, where negative values leave that many
+// cells free at the edge of the screen.
+func (c coord) length(size int) int {
+	switch {
+	case c.percent:
+		return size * c.value / 100
+	case c.value < 0:
+		return max(0, size+c.value)
+	}
+	return c.value
+}
+
+// Widget returns the widget with the given section name, or nil.
+func (layout *Layout) Widget(name string) *Widget {
+	for i := range layout.Widgets {
+		if layout.Widgets[i].Name == name {
+			return &layout.Widgets[i]
+		}
+	}
+	return nil
+}
+
+// Rect resolves the widget's position on a screen of h rows and w columns.
+func (widget Widget) Rect(h, w int) Rect {
+	return Rect{
+		X: widget.Col.position(w),
+		Y: widget.Row.position(h),
+		W: widget.Width.length(w),
+		H: widget.Height.length(h),
+	}
+}
+
+// LoadLayout reads a layout file, or the built-in layout when path is empty.
+// Each section places one widget; the widget type is the section name unless
+// a "type" key is given, so a layout can hold several widgets of one type.
+func LoadLayout(path string) (*Layout, error) {
+	var source interface{} = []byte(defaultLayout)
+	if path != "" {
+		source = path
+	}
+	// Shadows allow an art widget to have several "schedule" lines
+	cfg, err := ini.LoadSources(ini.LoadOptions{AllowShadows: true}, source)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := &Layout{}
+	for _, section := range cfg.Sections() {
+		if section.Name() == ini.DefaultSection {
+			continue
+		}
+
+		widget := Widget{
+			Name:     section.Name(),
+			Type:     section.Key("type").MustString(section.Name()),
+			Below:    section.Key("below").String(),
+			File:     section.Key("file").String(),
+			Mode:     section.Key("mode").In(artModeRotate, []string{artModeRotate, artModeRandom}),
+			Interval: section.Key("interval").MustDuration(0),
+		}
+		if !widgetTypes[widget.Type] {
+			return nil, fmt.Errorf("section [%s]: unknown widget type %q", widget.Name, widget.Type)
+		}
+		for key, target := range map[string]*coord{
+			"row":    &widget.Row,
+			"col":    &widget.Col,
+			"width":  &widget.Width,
+			"height": &widget.Height,
+		} {
+			if *target, err = parseCoord(section.Key(key).String()); err != nil {
+				return nil, fmt.Errorf("section [%s] %s: %w", widget.Name, key, err)
+			}
+		}
+		if section.HasKey("schedule") {
+			for _, entry := range section.Key("schedule").ValueWithShadows() {
+				rule, err := parseArtRule(entry)
+				if err != nil {
+					return nil, fmt.Errorf("section [%s]: %w", widget.Name, err)
+				}
+				widget.Schedule = append(widget.Schedule, rule)
+			}
+		}
+		if widget.Type == "art" && widget.File == "" {
+			return nil, fmt.Errorf("section [%s]: art widgets need a file", widget.Name)
+		}
+		layout.Widgets = append(layout.Widgets, widget)
+	}
+
+	// Widgets can only sit below one drawn before them, whose height is known
+	for i, widget := range layout.Widgets {
+		if widget.Below == "" {
+			continue
+		}
+		found := false
+		for _, earlier := range layout.Widgets[:i] {
+			found = found || earlier.Name == widget.Below
+		}
+		if !found {
+			return nil, fmt.Errorf("section [%s]: below must name a widget earlier in the layout, not %q", widget.Name, widget.Below)
+		}
+	}
+	return layout, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const leaderboardSize = 10
+
+// Leaderboard periods, in the order the leaderboard screen cycles through them
+var leaderPeriods = []struct {
+	Key   string // Bulletin placeholder prefix
+	Title string
+}{
+	{"DAY", "Today"},
+	{"WEEK", "This Week"},
+	{"MONTH", "This Month"},
+	{"ALL", "All Time"},
+}
+
+// Leaderboard ranks users by one measure.
+type Leaderboard struct {
+	Key     string // Bulletin placeholder name
+	Title   string
+	Leaders []Ranked // At most leaderboardSize, best first
+}
+
+// Value formats a leader's count: minutes for time online, else a number.
+func (board Leaderboard) Value(count int) string {
+	if board.Key == "TIME" {
+		return formatUptime(time.Duration(count) * time.Minute)
+	}
+	return fmt.Sprint(count)
+}
+
+// periodStart returns when a leaderboard period began: midnight today, the
+// Monday of this week, the 1st of the month, or the zero time for all time.
+func periodStart(period int, now time.Time) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch leaderPeriods[period].Key {
+	case "DAY":
+		return today
+	case "WEEK":
+		return today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	case "MONTH":
+		return today.AddDate(0, 0, 1-today.Day())
+	}
+	return time.Time{}
+}
+
+// ComputeLeaderboards ranks users by calls, time online, messages posted and
+// doors launched over the sessions that started since the given time, not
+// counting excludeUser.
+func ComputeLeaderboards(sessions []Session, since time.Time) []Leaderboard {
+	calls := make(map[string]int)
+	minutes := make(map[string]int)
+	posts := make(map[string]int)
+	doors := make(map[string]int)
+	for _, session := range sessions {
+		if session.User == "" || session.User == excludeUser || session.Start.Before(since) {
+			continue
+		}
+		if !session.Login.IsZero() {
+			calls[session.User]++
+		}
+		minutes[session.User] += int(session.Duration().Minutes())
+		posts[session.User] += session.Posts
+		doors[session.User] += len(session.Doors)
+	}
+
+	boards := []Leaderboard{
+		{Key: "CALLS", Title: "Most Calls", Leaders: rank(calls)},
+		{Key: "TIME", Title: "Most Time Online", Leaders: rank(minutes)},
+		{Key: "POSTS", Title: "Most Messages Posted", Leaders: rank(posts)},
+		{Key: "DOORS", Title: "Most Door Launches", Leaders: rank(doors)},
+	}
+	for i := range boards {
+		// Nobody leads with nothing
+		leaders := boards[i].Leaders[:0]
+		for _, leader := range boards[i].Leaders {
+			if leader.Count > 0 && len(leaders) < leaderboardSize {
+				leaders = append(leaders, leader)
+			}
+		}
+		boards[i].Leaders = leaders
+	}
+	return boards
+}
+
+// drawLeaderScreen shows the leaderboards for one period in place of the WFC
+// screen, two by two.
+func (s *Screen) drawLeaderScreen() {
+	period := leaderPeriods[s.Period]
+	boards := ComputeLeaderboards(s.History.Sessions(), periodStart(s.Period, time.Now()))
+	s.historyDrawn = len(s.History.Sessions())
+
+	MoveCursor(1, 1)
+	PrintSpaces(s.Width, s.Theme.Footer)
+	MoveCursor(1, 1)
+	fmt.Fprint(Output, s.Theme.Footer+s.Theme.FooterLabel+" Leaderboards: "+period.Title+Reset)
+
+	rows := max(1, min(leaderboardSize, (s.Height-6)/2-1))
+	for i, board := range boards {
+		x, y := 1+(i%2)*40, 3+(i/2)*(rows+2)
+		MoveCursor(x, y)
+		fmt.Fprint(Output, formatCell(" "+board.Title, len(board.Title)+1, s.Theme.LastUserLabel))
+		for j := 0; j < rows && j < len(board.Leaders); j++ {
+			leader := board.Leaders[j]
+			MoveCursor(x, y+1+j)
+			fmt.Fprint(Output,
+				formatCell(fmt.Sprintf(" %2d. %-20s", j+1, PadOrTruncate(leader.Name, 20)), 25, s.Theme.User)+
+					formatCell(fmt.Sprintf("%12s", board.Value(leader.Count)), 12, s.Theme.LastUser),
+			)
+		}
+	}
+}
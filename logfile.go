@@ -0,0 +1,59 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gzipFile closes both the decompressor and the file under it.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f gzipFile) Close() error {
+	f.Reader.Close()
+	return f.file.Close()
+}
+
+// openLog opens a log file for reading, decompressing rotated .gz logs.
+func openLog(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return gzipFile{Reader: reader, file: file}, nil
+}
+
+// previousLog finds the rotated log to read before the current one: the most
+// recently modified file matching pattern, relative to the log directory.
+// It returns "" when there's no pattern or no match.
+func previousLog(pattern, logFilePath string) string {
+	if pattern == "" {
+		return ""
+	}
+	files, _ := filepath.Glob(resolvePath(filepath.Dir(logFilePath), pattern))
+	newest := ""
+	var newestTime int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() || filepath.Clean(file) == filepath.Clean(logFilePath) {
+			continue
+		}
+		if modified := info.ModTime().UnixNano(); newest == "" || modified > newestTime {
+			newest, newestTime = file, modified
+		}
+	}
+	return newest
+}
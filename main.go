@@ -5,11 +5,10 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/hpcloud/tail"
@@ -17,158 +16,38 @@ import (
 	"gopkg.in/ini.v1"
 )
 
-type NodeStatus struct {
-	User     string
-	Location string
-}
-
 const (
 	// Column widths
 	nodeColWidth     = 5
 	userColWidth     = 20
 	locationColWidth = 20
+	geoColWidth      = 24 // Optional From column, when GeoIP is set up
 	systemNameWidth  = 66
 	quitMessageWidth = 14
 	totalTableWidth  = nodeColWidth + userColWidth + locationColWidth
-	headerHeight     = 4
 
 	// Maximum number of lines to read from the log file (whole file is loaded on startup)
 	maxLogLines = 200
-
-	// Text colors
-	colorNode               = WhiteHi
-	colorNodeLabel          = Cyan
-	colorUser               = CyanHi
-	colorUserLabelUnet      = Green
-	colorUserLabel          = Cyan
-	colorLocation           = CyanHi
-	colorLocationLabel      = Cyan
-	colorLastUserLabel      = Yellow
-	colorLastUser           = YellowHi
-	colorSeparator          = BlackHi
-	colorSystemName         = Red
-	colorQuitMessage        = Red
-	colorBackgroundBar      = BgWhiteHi
-	colorBackgroundBarLabel = Red
 )
 
 var (
-	// Regular expressions for parsing log entries
-	logPattern        = regexp.MustCompile(`INFO: (.+?) (logged in|loading menu|running door|running script|listing messages|posting a message) (.+?) on node (\d+)`)
-	disconnectPattern = regexp.MustCompile(`INFO: Node (\d+) logged off`)
-	loginPattern      = regexp.MustCompile(`INFO: (.+?) logged in on node (\d+)`)
-	connectionPattern = regexp.MustCompile(`INFO: Connection From: (.+?) on Node (\d+)`)
-	menuPattern       = regexp.MustCompile(`INFO: (.+?) loading menu (.+?) on node (\d+)`)
-	newUserPattern    = regexp.MustCompile(`INFO: New user signing up on node (\d+)`)
-
 	// Change "sysop" to the actual username you want to exclude
 	excludeUser = "j0hnny a1pha"
 )
 
-// Function to count today's calls excluding the specified user
-func countTodaysCalls(logFilePath string) int {
-	// Get the current date in YYYY-MM-DD format
-	today := time.Now().Format("2006-01-02")
-	count := 0
-
-	// Open the log file
-	file, err := os.Open(logFilePath)
-	if err != nil {
-		log.Printf("Error opening log file: %v", err)
-		return count
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		// Check if the log line is from today
-		if strings.HasPrefix(line, today) {
-			// Check for a login match
-			if loginMatches := loginPattern.FindStringSubmatch(line); len(loginMatches) > 0 {
-				user := loginMatches[1]
-				// Increment the count if the user is not the one to exclude
-				if user != excludeUser {
-					count++
-				}
+func findLastLoggedOffUser(previous, logFilePath string, numLines int) string {
+	// A call spanning the rotation starts in the rotated log
+	var lines []string
+	if previous != "" {
+		if file, err := openLog(previous); err == nil {
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
 			}
+			file.Close()
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Printf("Error reading log file: %v", err)
-	}
-
-	return count
-}
-
-func formatCell(text string, width int, color string) string {
-	return Reset + color + PadOrTruncate(text, width) + Reset
-}
-
-// DrawTableRow draws a single row in the table.
-func DrawTableRow(nodeNum int, status NodeStatus, maxNodes int, talismanPath string) {
-	// Calculate the row position based on nodeNum
-	row := headerHeight + 2 + nodeNum
-
-	// Move cursor to the specific row and column
-	MoveCursor(1, row)
-
-	// Determine color based on user status
-	userColor := colorUser
-	if status.User == "waiting for caller" {
-		userColor = colorUserLabelUnet // Default color for "waiting for caller"
-	}
-
-	// Format and print the node data
-	nodeStr := strconv.Itoa(nodeNum)
-	fmt.Println(
-		" " +
-			formatCell(nodeStr, nodeColWidth, colorNode) +
-			formatCell(status.User, userColWidth, userColor) +
-			formatCell(status.Location, locationColWidth, colorLocation),
-	)
-}
-
-// DrawTable draws the full table of nodes and user statuses.
-func DrawTable(nodeStatus map[string]NodeStatus, maxNodes int, talismanPath string, oldState *term.State) {
-	// Restore terminal to cooked mode for drawing
-	term.Restore(int(os.Stdin.Fd()), oldState)
-	defer term.MakeRaw(int(os.Stdin.Fd())) // Return to raw mode after drawing
-
-	// Clear the screen
-	ClearScreen()
-
-	// Draw header art
-	DisplayAnsiFile(filepath.Join(talismanPath, "gfiles", "wfc.ans"), true)
-	fmt.Print(BgBlack)
-
-	// Move the cursor to the line after the ANSI art (2 rows tall), offset by 1 column
-	MoveCursor(1, headerHeight+1) // Move cursor to column 2 instead of 1
-
-	// Draw table headers with colors
-	fmt.Println(
-		" " +
-			formatCell("Node", nodeColWidth, colorNodeLabel) +
-			formatCell("User", userColWidth, colorUserLabel) +
-			formatCell("Location", locationColWidth, colorLocationLabel),
-	)
-	fmt.Println(" " + strings.Repeat(colorSeparator+"-", totalTableWidth) + Reset)
-
-	// Draw all rows initially
-	for i := 1; i <= maxNodes; i++ {
-		status, exists := nodeStatus[strconv.Itoa(i)]
-
-		if !exists {
-			status = NodeStatus{User: "waiting for caller", Location: "-"}
-		}
-
-		DrawTableRow(i, status, maxNodes, talismanPath)
-	}
-}
-
-func findLastLoggedOffUser(logFilePath string, numLines int) string {
 	// Use tail to read the entire file
 	t, err := tail.TailFile(logFilePath, tail.Config{
 		Follow:   false,
@@ -180,7 +59,6 @@ func findLastLoggedOffUser(logFilePath string, numLines int) string {
 	}
 	defer t.Cleanup()
 
-	var lines []string
 	for line := range t.Lines {
 		lines = append(lines, line.Text)
 	}
@@ -222,30 +100,12 @@ func checkError(err error, msg string) {
 	}
 }
 
-// Draws the footer with system name and quit instruction
-func drawFooter(h, w int, systemName string) {
-	MoveCursor(1, h)
-	PrintSpaces(w, colorBackgroundBar)
-
-	MoveCursor(1, h)
-	fmt.Printf(colorBackgroundBar+colorBackgroundBarLabel+" System Name: %s"+Reset, systemName)
-	MoveCursor(w-13, h)
-	fmt.Printf(colorBackgroundBar + colorBackgroundBarLabel + "Q/ESC to Quit" + Reset)
-}
-
 func main() {
-	// Hide the cursor
-	CursorHide()
-
-	// Get terminal dimensions
-	h, w, err := GetTermSize()
-	if err != nil {
-		log.Printf("Error getting terminal size, using default: %v", err)
-		h, w = 25, 80 // default size
-	}
-
 	// Parse command-line argument for Talisman installation path
 	talismanPath := flag.String("path", "", "Path to the Talisman BBS installation")
+	dropFilePath := flag.String("door", "", "Run as a door using this DOOR32.SYS or DOOR.SYS drop file")
+	configPath := flag.String("config", "", "Path to wfc.ini (defaults to wfc.ini in the Talisman directory)")
+	importLogs := flag.Bool("import", false, "Import every log, including rotated ones, into the caller history and exit")
 	flag.Parse()
 
 	if *talismanPath == "" {
@@ -255,6 +115,20 @@ func main() {
 	cfg, err := loadConfig(*talismanPath)
 	checkError(err, "loading configuration")
 
+	if *configPath == "" {
+		*configPath = filepath.Join(*talismanPath, "wfc.ini")
+	}
+	config, err := loadWFCConfig(*configPath, *talismanPath)
+	checkError(err, "loading wfc.ini")
+
+	layout, err := LoadLayout(config.Screen.Layout)
+	checkError(err, "loading screen layout")
+
+	themes, err := LoadThemes(config.Screen.Themes)
+	checkError(err, "loading themes")
+	themeIndex, err := FindTheme(themes, config.Screen.Theme)
+	checkError(err, "loading themes")
+
 	// Get required values from the ini file
 	logPath := cfg.Section("paths").Key("log path").String()
 	if logPath == "" {
@@ -275,6 +149,38 @@ func main() {
 	// Construct the full log file path
 	logFilePath := filepath.Join(*talismanPath, logPath, "talisman.log")
 
+	history, err := LoadHistory(config.History)
+	checkError(err, "loading caller history")
+
+	if *importLogs {
+		if config.History == "" {
+			log.Fatal("No caller history file to import into. Please set one in the [history] section of wfc.ini.")
+		}
+		logs, added, err := ImportLogs(logFilePath, config.Parser, history)
+		checkError(err, "importing logs")
+		fmt.Printf("Imported %d new sessions from %d logs into %s\n", added, logs, config.History)
+		return
+	}
+
+	if *dropFilePath != "" {
+		runDoor(*dropFilePath, *talismanPath, logFilePath, maxNodes, systemName, layout, themes[themeIndex], config)
+		return
+	}
+
+	// Loaded before the screen is taken over, so a skipped line can be read
+	bans, err := LoadBans(config.Bans)
+	checkError(err, "loading bans")
+
+	// Hide the cursor
+	CursorHide()
+
+	// Get terminal dimensions
+	h, w, err := GetTermSize()
+	if err != nil {
+		log.Printf("Error getting terminal size, using default: %v", err)
+		h, w = 25, 80 // default size
+	}
+
 	// Check if the log file exists
 	if _, err := os.Stat(logFilePath); os.IsNotExist(err) {
 		log.Printf("Log file not found at: %s. Starting with an empty log.", logFilePath)
@@ -283,12 +189,11 @@ func main() {
 		file.Close()
 	}
 
-	// Initialize variables for node status, active users and log tailing
-	nodeStatus := make(map[string]NodeStatus, maxNodes)
-	activeUsers := make(map[string]string) // node number to username mapping
-
-	// Start tailing the log file
-	t, err := tail.TailFile(logFilePath, tail.Config{Follow: true})
+	// Start tailing the log file, following it through log rotation like
+	// tail -F. Truncated files are always reopened. Polling is used as the
+	// tailer's inotify watcher can miss writes to a recreated file, and the
+	// tailer's own messages would draw over the screen, so they're dropped
+	t, err := tail.TailFile(logFilePath, tail.Config{Follow: true, ReOpen: true, Poll: true, Logger: tail.DiscardingLogger})
 	checkError(err, "Failed to tail file")
 
 	// Enter raw mode to take full control of the terminal
@@ -298,123 +203,255 @@ func main() {
 		checkError(term.Restore(int(os.Stdin.Fd()), oldState), "restoring terminal state")
 	}()
 
-	// Display the initial screen
-	lastUser := findLastLoggedOffUser(logFilePath, maxLogLines) // Read last 100 lines to get recent entries
-	DrawTable(nodeStatus, maxNodes, *talismanPath, oldState)
-
-	// Count today's calls
-	todaysCalls := countTodaysCalls(logFilePath)
+	// Read key presses in the background so they can be handled with log events
+	keys := make(chan byte)
+	go ReadKeys(keys)
 
-	// Draw the initial footer
-	drawFooter(h, w, systemName)
+	// Play the intro before the initial screen
+	artDir := filepath.Join(*talismanPath, "gfiles")
+	if intro := introPool(config.Intro.Startup, artDir); intro != nil {
+		PlayAnsiFile(intro.Pick(time.Now()), config.Intro.Baud, config.Intro.Hold, keys)
+	}
+	connectArt := introPool(config.Intro.Connect, artDir)
 
-	// Print the last user and today's calls
-	MoveCursor(1, h-3)
-	fmt.Printf(colorLastUserLabel+" Last User:"+Reset+colorLastUser+" %s\n"+Reset, lastUser)
-	MoveCursor(1, h-2)
-	fmt.Printf(colorLastUserLabel+" Today's Calls: "+Reset+colorLastUser+"%d (excluding %s)\n"+Reset, todaysCalls, excludeUser)
+	// Display the initial screen
+	state := NewState(maxNodes, "None")
+	state.BotTime = config.Detect.BotTime
+	// Callers from before the last rotation are only in the rotated log. Its
+	// calls go to the history, and to the tracker that carries on with the
+	// current log, so a call spanning the rotation is joined as --import
+	// joins it
+	tracker := NewSessionTracker()
+	previous := previousLog(config.Log.Previous, logFilePath)
+	if previous != "" {
+		sessions, err := state.Replay(previous, config.Parser, tracker)
+		if err != nil {
+			log.Printf("Error reading rotated log %s: %v", previous, err)
+		}
+		if _, err := history.Add(sessions...); err != nil {
+			log.Printf("Error saving caller history: %v", err)
+		}
+	}
+	state.LastUser = findLastLoggedOffUser(previous, logFilePath, maxLogLines)
+	screen := &Screen{
+		Layout:     layout,
+		Theme:      themes[themeIndex],
+		State:      state,
+		Height:     h,
+		Width:      w,
+		SystemName: systemName,
+		ArtDir:     artDir,
+		Hint:       "S Stats  L Leaders  D Details  B Bans  T Theme  Q/ESC Quit",
+		Started:    time.Now(),
+		Events:     config.Events,
+		History:    history,
+		ShowGeo:    config.Parser.GeoIP != nil,
+		Hostnames:  NewHostnames(config.DNS, net.DefaultResolver),
+		Detector:   NewDetector(config.Detect),
+		Bans:       bans,
+	}
+	screen.Draw()
+	saver := NewScreensaver(config.Screensaver, screen)
+	hooks := NewHooks(config.Hooks)
+	scripts, err := LoadScripts(config.Scripts, screen)
+	checkError(err, "loading scripts")
+	defer scripts.Close()
 
 	// Create a ticker to limit the redraw frequency
 	ticker := time.NewTicker(500 * time.Millisecond) // Redraw every 500ms
 	defer ticker.Stop()
 
-	// Continuously update the screen as new log entries are read
-	go func() {
-		for {
-			select {
-			case line := <-t.Lines:
-				updatedNodes := make(map[int]NodeStatus) // Track updated nodes
-
-				if connectionMatches := connectionPattern.FindStringSubmatch(line.Text); len(connectionMatches) > 0 {
-					ip := connectionMatches[1]
-					node := connectionMatches[2]
-					// Update NodeStatus with "Unknown User" in User column and IP in Location column
-					nodeStatus[node] = NodeStatus{User: "Unknown User", Location: ip}
-					nodeNum, _ := strconv.Atoi(node)
-					updatedNodes[nodeNum] = nodeStatus[node]
-				} else if loginMatches := loginPattern.FindStringSubmatch(line.Text); len(loginMatches) > 0 {
-					node := loginMatches[2]
-					user := loginMatches[1]
-					// Set the user and display "logging in..." in the Location column
-					nodeStatus[node] = NodeStatus{User: user, Location: "logging in..."}
-					nodeNum, _ := strconv.Atoi(node)
-					updatedNodes[nodeNum] = nodeStatus[node]
-
-					// Track the logged-in user
-					activeUsers[node] = user
-
-					// Recount today's calls
-					todaysCalls = countTodaysCalls(logFilePath)
-				} else if newUserMatches := newUserPattern.FindStringSubmatch(line.Text); len(newUserMatches) > 0 {
-					node := newUserMatches[1]
-					nodeNum, _ := strconv.Atoi(node)
-					// Update NodeStatus with "New User" and "Signing up..." information
-					nodeStatus[node] = NodeStatus{User: "New User", Location: "Signing up..."}
-					updatedNodes[nodeNum] = nodeStatus[node]
-
-					// Track the new user as a placeholder until they log in
-					// Do not add "New User" to activeUsers since it's not the actual username
-				} else if menuMatches := menuPattern.FindStringSubmatch(line.Text); len(menuMatches) > 0 {
-					user := menuMatches[1]
-					menuName := strings.Title(strings.TrimSuffix(filepath.Base(menuMatches[2]), ".toml")) // Capitalize the menu name
-					node := menuMatches[3]
-					nodeStatus[node] = NodeStatus{User: user, Location: "At " + menuName + " Menu"}
-					nodeNum, _ := strconv.Atoi(node)
-					updatedNodes[nodeNum] = nodeStatus[node]
-				} else if matches := logPattern.FindStringSubmatch(line.Text); len(matches) > 0 {
-					node := matches[4]
-					user := matches[1]
-					location := matches[3]
-
-					// Simplify the location and handle specific cases
-					location = strings.TrimPrefix(location, "menu ")
-					location = strings.TrimPrefix(location, "menus/")
-					location = strings.TrimSuffix(location, ".toml")
-					location = "At " + strings.Title(location)
-
-					nodeStatus[node] = NodeStatus{User: user, Location: location}
-					nodeNum, _ := strconv.Atoi(node)
-					updatedNodes[nodeNum] = nodeStatus[node]
-				} else if disconnectMatches := disconnectPattern.FindStringSubmatch(line.Text); len(disconnectMatches) > 0 {
-					node := disconnectMatches[1]
-					// Ensure we only update lastUser if there was an actual user logged in
-					if user, exists := activeUsers[node]; exists && user != "New User" {
-						lastUser = user // Update the last user to the one who logged off
-					}
-					delete(activeUsers, node) // Remove the user from the active users
-					delete(nodeStatus, node)
-					nodeNum, _ := strconv.Atoi(node)
-					updatedNodes[nodeNum] = NodeStatus{User: "waiting for caller", Location: "-"}
+	// Bulletins are regenerated on their own, slower, schedule
+	var bulletinTick <-chan time.Time
+	if config.Bulletins.Enabled {
+		// Write them straight away too, so they're there before the first tick
+		if err := writeBulletins(config.Bulletins, state, history, systemName); err != nil {
+			log.Printf("Error writing bulletins: %v", err)
+		}
+		bulletinTicker := time.NewTicker(config.Bulletins.Interval)
+		defer bulletinTicker.Stop()
+		bulletinTick = bulletinTicker.C
+	}
 
-					// Recount today's calls
-					todaysCalls = countTodaysCalls(logFilePath)
-				}
+	// The screensaver animates faster than the screen refreshes
+	var saverTick <-chan time.Time
+	if config.Screensaver.Enabled {
+		saverTicker := time.NewTicker(saverFrameInterval)
+		defer saverTicker.Stop()
+		saverTick = saverTicker.C
+	}
 
-				// Only redraw if there are changes
-				select {
-				case <-ticker.C:
-					// Redraw only on ticker or when there's a change
-					for nodeNum, status := range updatedNodes {
-						DrawTableRow(nodeNum, status, maxNodes, *talismanPath)
-					}
+	// Keys go to the connect art while it plays, and it says when it's done
+	var artKeys chan byte
+	artDone := make(chan struct{})
 
-					// Update the last user display and today's calls
-					MoveCursor(1, h-3)
-					fmt.Print("\033[K") // Clear the line
-					fmt.Printf(colorLastUserLabel+" Last User:"+Reset+colorLastUser+" %s\n"+Reset, lastUser)
-					MoveCursor(1, h-2)
-					fmt.Print("\033[K") // Clear the line
-					fmt.Printf(colorLastUserLabel+" Today's Calls: "+Reset+colorLastUser+"%d (excluding %s)\n"+Reset, todaysCalls, excludeUser)
+	// What to do with the answer to the footer prompt
+	var answered func(string)
 
-					// Move the cursor to the bottom of the screen
-					drawFooter(h, w, systemName)
+	// Continuously update the screen as new log entries are read
+	for {
+		select {
+		case line := <-t.Lines:
+			// The whole log is read on startup; only react to new callers
+			live := !parseLogTime(line.Text).Before(screen.Started.Truncate(time.Second))
+			scripts.Line(line.Text, live)
+			if ev, ok := config.Parser.Parse(line.Text); ok {
+				state.Apply(ev)
+				if session, done := tracker.Apply(ev); done {
+					if _, err := history.Add(session); err != nil {
+						log.Printf("Error saving caller history: %v", err)
+					}
+				}
+				redraw := saver.Activity()
+				if ev.Type == EventConnect && live && connectArt != nil && artKeys == nil {
+					// Played in the background so events are still handled as
+					// they come, as in a flood; connections while it plays get none
+					artKeys = make(chan byte, 1)
+					screen.Covered = true
+					go func(file string, keys <-chan byte) {
+						PlayAnsiFile(file, config.Intro.Baud, config.Intro.Hold, keys)
+						artDone <- struct{}{}
+					}(connectArt.Pick(time.Now()), artKeys)
+				}
+				if redraw {
+					screen.Draw()
+				}
+				suspect, flagged := screen.Detector.Apply(ev, live)
+				if live {
+					Alert(config.Alerts, ev, screen)
+					hooks.Run(ev)
+					if flagged {
+						AlertSuspect(config.Alerts, suspect, screen)
+						if config.Bans.Auto {
+							if err := bans.Ban(suspect.IP, suspect.Why(), banByWFC); err != nil {
+								screen.Notify(fmt.Sprintf("Error banning %s: %v", suspect.IP, err))
+							} else {
+								screen.Notify("Banned " + suspect.String())
+							}
+						}
+					}
+				}
+				scripts.Event(ev, live)
+			}
+		case text := <-hooks.Errors():
+			screen.Notify(text)
+		case text := <-bans.Errors():
+			screen.Notify(text)
+		case ip := <-screen.Hostnames.Resolved():
+			// Shown on the next tick
+			state.Touch(ip)
+		case now := <-ticker.C:
+			if expired, err := bans.Expire(now); err != nil {
+				screen.Notify(fmt.Sprintf("Error expiring bans: %v", err))
+			} else if expired > 0 && screen.View == viewBans {
+				screen.Draw()
+			}
+			// Redraw only what changed since the last tick
+			if !saver.Active() {
+				screen.Refresh()
+			}
+		case <-artDone:
+			artKeys = nil
+			screen.Covered = false
+			screen.Draw()
+		case now := <-saverTick:
+			if !screen.Covered {
+				saver.Tick(now)
+			}
+		case <-bulletinTick:
+			if err := writeBulletins(config.Bulletins, state, history, systemName); err != nil {
+				log.Printf("Error writing bulletins: %v", err)
+			}
+		case key := <-keys:
+			if artKeys != nil {
+				// The key only cuts the art short
+				select {
+				case artKeys <- key:
 				default:
-					// Skip redraw if not needed
 				}
+				continue
+			}
+			if saver.Activity() {
+				// The key only wakes the screen
+				screen.Draw()
+				continue
+			}
+			if screen.Prompting() {
+				if answer, done := screen.Type(key); done && answer != "" {
+					answered(answer)
+				}
+				continue
+			}
+			switch key {
+			case 'q', 'Q', 27: // 27 is the ASCII code for the Escape key
+				CursorShow()
+				return
+			case 's', 'S':
+				// Switch between the WFC and the stats screen
+				if screen.View == viewStats {
+					screen.View = viewWFC
+				} else {
+					screen.View = viewStats
+				}
+				screen.Draw()
+			case 'l', 'L':
+				// Show the leaderboards, then each period in turn, then the WFC
+				if screen.View != viewLeaders {
+					screen.View, screen.Period = viewLeaders, 0
+				} else if screen.Period++; screen.Period == len(leaderPeriods) {
+					screen.View = viewWFC
+				}
+				screen.Draw()
+			case 'd', 'D':
+				// Switch between the WFC and the node details
+				if screen.View == viewDetails {
+					screen.View = viewWFC
+				} else {
+					screen.View = viewDetails
+				}
+				screen.Draw()
+			case 'b', 'B':
+				// Switch between the WFC and the ban list
+				if screen.View == viewBans {
+					screen.View = viewWFC
+				} else {
+					screen.View = viewBans
+				}
+				screen.Draw()
+			case 'a', 'A':
+				// Ban an IP typed in by the sysop
+				if screen.View != viewBans {
+					break
+				}
+				screen.Prompt("Ban IP: ")
+				answered = func(ip string) {
+					if err := bans.Ban(ip, "banned by the sysop", banBySysop); err != nil {
+						screen.Notify(err.Error())
+						return
+					}
+					screen.Draw()
+					screen.Notify("Banned " + ip)
+				}
+			case 'u', 'U':
+				// Lift a ban
+				if screen.View != viewBans {
+					break
+				}
+				screen.Prompt("Unban IP: ")
+				answered = func(ip string) {
+					if err := bans.Unban(ip, banBySysop); err != nil {
+						screen.Notify(err.Error())
+						return
+					}
+					screen.Draw()
+					screen.Notify("Unbanned " + ip)
+				}
+			case 't', 'T':
+				// Cycle to the next theme
+				themeIndex = (themeIndex + 1) % len(themes)
+				screen.Theme = themes[themeIndex]
+				screen.Draw()
 			}
 		}
-	}()
-
-	// Handle user input
-	HandleKeyPress()
+	}
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EventType identifies the kind of activity found on a talisman.log line.
+type EventType int
+
+const (
+	EventNone EventType = iota
+	EventConnect
+	EventLogin
+	EventNewUser
+	EventMenu
+	EventActivity
+	EventLogoff
+	EventFailedLogin // A bad password, from a WARN or ERROR line
+)
+
+// Actions of EventActivity events that are counted and can be hooked
+const (
+	actionDoor = "running door"
+	actionPost = "posting a message"
+)
+
+// Event is a single parsed talisman.log entry.
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	Node     string
+	User     string
+	IP       string // Set for EventConnect
+	Geo      string // Where IP is, from the GeoIP databases
+	Network  string // Autonomous system IP belongs to, from the GeoIP databases
+	Action   string // logPattern verb for EventActivity, e.g. "running door"
+	Location string // Raw menu, door or script name
+	Label    string // Friendly name for Location from the labels table, if any
+}
+
+// Captures a sysop pattern can use to fill in an event
+var patternCaptures = []string{"node", "user", "location", "ip", "action"}
+
+// LogPattern is a pattern from wfc.ini that turns lines into events, with
+// named captures filling in the event's fields.
+type LogPattern struct {
+	Type    EventType
+	Action  string // Fixed action for door and post patterns
+	Pattern *regexp.Regexp
+}
+
+// NewLogPattern compiles a pattern for an event: connect, login, newuser,
+// menu, activity, door, post, logoff or failedlogin. Patterns must capture
+// the node.
+func NewLogPattern(event, pattern string) (LogPattern, error) {
+	p := LogPattern{}
+	switch event {
+	case "connect":
+		p.Type = EventConnect
+	case "login":
+		p.Type = EventLogin
+	case "newuser":
+		p.Type = EventNewUser
+	case "menu":
+		p.Type = EventMenu
+	case "activity":
+		p.Type = EventActivity
+	case "door":
+		p.Type, p.Action = EventActivity, actionDoor
+	case "post":
+		p.Type, p.Action = EventActivity, actionPost
+	case "logoff":
+		p.Type = EventLogoff
+	case "failedlogin":
+		p.Type = EventFailedLogin
+	default:
+		return LogPattern{}, fmt.Errorf("unknown event %q", event)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return LogPattern{}, err
+	}
+	if re.SubexpIndex("node") == -1 {
+		return LogPattern{}, fmt.Errorf("pattern %q has no (?P<node>...) capture", pattern)
+	}
+	p.Pattern = re
+	return p, nil
+}
+
+// Parser turns log lines into events using the sysop's patterns, then the
+// built-in ones, names locations from the labels table and places IPs with
+// the GeoIP databases.
+type Parser struct {
+	Patterns []LogPattern
+	Labels   map[string]string // Friendly names by lower case raw name
+	GeoIP    *GeoIP            // No lookups when nil
+}
+
+// Parse turns a log line into an Event, like ParseLine.
+func (p *Parser) Parse(line string) (Event, bool) {
+	ev, ok := p.match(line)
+	if !ok {
+		ev, ok = ParseLine(line)
+	}
+	if ok {
+		ev.Label = p.label(ev.Location)
+		if ev.IP != "" {
+			ev.Geo, ev.Network = p.GeoIP.Lookup(ev.IP)
+		}
+	}
+	return ev, ok
+}
+
+// match tries the sysop's patterns.
+func (p *Parser) match(line string) (Event, bool) {
+	for _, pattern := range p.Patterns {
+		matches := pattern.Pattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		ev := Event{Type: pattern.Type, Time: parseLogTime(line), Action: pattern.Action}
+		for _, name := range patternCaptures {
+			i := pattern.Pattern.SubexpIndex(name)
+			if i == -1 {
+				continue
+			}
+			switch name {
+			case "node":
+				ev.Node = matches[i]
+			case "user":
+				ev.User = matches[i]
+			case "location":
+				ev.Location = matches[i]
+			case "ip":
+				ev.IP = matches[i]
+			case "action":
+				ev.Action = matches[i]
+			}
+		}
+		return ev, true
+	}
+	return Event{}, false
+}
+
+// label looks a location up in the labels table, as written in the log or
+// without its menus/ directory and .toml extension.
+func (p *Parser) label(location string) string {
+	if location == "" || len(p.Labels) == 0 {
+		return ""
+	}
+	name := strings.ToLower(strings.TrimPrefix(location, "menu "))
+	for _, candidate := range []string{
+		name,
+		filepath.Base(name),
+		strings.TrimSuffix(filepath.Base(name), ".toml"),
+	} {
+		if label, ok := p.Labels[candidate]; ok {
+			return label
+		}
+	}
+	return ""
+}
+
+var (
+	// Regular expressions for parsing log entries
+	logPattern        = regexp.MustCompile(`INFO: (.+?) (logged in|loading menu|running door|running script|listing messages|posting a message) (.+?) on node (\d+)`)
+	disconnectPattern = regexp.MustCompile(`INFO: Node (\d+) logged off`)
+	loginPattern      = regexp.MustCompile(`INFO: (.+?) logged in on node (\d+)`)
+	connectionPattern = regexp.MustCompile(`INFO: Connection From: (.+?) on Node (\d+)`)
+	menuPattern       = regexp.MustCompile(`INFO: (.+?) loading menu (.+?) on node (\d+)`)
+	newUserPattern    = regexp.MustCompile(`INFO: New user signing up on node (\d+)`)
+
+	// Warnings and errors about bad passwords, however they're worded, with
+	// the user when it's given. A failedlogin pattern in wfc.ini overrides it
+	badPasswordPattern = regexp.MustCompile(`(?i)(?:WARN|ERROR): .*?(?:bad|invalid|wrong|incorrect) password(?: for (?:user )?(.+?))? on node (\d+)`)
+
+	// Timestamp at the start of each log line
+	timePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})[ T](\d{2}:\d{2}:\d{2})`)
+)
+
+// ParseLine turns a log line into an Event. The second return value is false
+// when the line doesn't describe any node activity.
+func ParseLine(line string) (Event, bool) {
+	ev := Event{Time: parseLogTime(line)}
+
+	if matches := connectionPattern.FindStringSubmatch(line); len(matches) > 0 {
+		ev.Type, ev.IP, ev.Node = EventConnect, matches[1], matches[2]
+	} else if matches := loginPattern.FindStringSubmatch(line); len(matches) > 0 {
+		ev.Type, ev.User, ev.Node = EventLogin, matches[1], matches[2]
+	} else if matches := newUserPattern.FindStringSubmatch(line); len(matches) > 0 {
+		ev.Type, ev.Node = EventNewUser, matches[1]
+	} else if matches := menuPattern.FindStringSubmatch(line); len(matches) > 0 {
+		ev.Type, ev.User, ev.Location, ev.Node = EventMenu, matches[1], matches[2], matches[3]
+	} else if matches := logPattern.FindStringSubmatch(line); len(matches) > 0 {
+		ev.Type, ev.User, ev.Action, ev.Location, ev.Node = EventActivity, matches[1], matches[2], matches[3], matches[4]
+	} else if matches := disconnectPattern.FindStringSubmatch(line); len(matches) > 0 {
+		ev.Type, ev.Node = EventLogoff, matches[1]
+	} else if matches := badPasswordPattern.FindStringSubmatch(line); len(matches) > 0 {
+		ev.Type, ev.User, ev.Node = EventFailedLogin, matches[1], matches[2]
+	} else {
+		return ev, false
+	}
+	return ev, true
+}
+
+// parseLogTime reads the timestamp at the start of a log line, falling back
+// to the current time when the line has none.
+func parseLogTime(line string) time.Time {
+	if matches := timePattern.FindStringSubmatch(line); len(matches) > 0 {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", matches[1]+" "+matches[2], time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
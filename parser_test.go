@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A call as talisman.log records it
+var sampleLog = []string{
+	"2026-10-18 21:04:02 INFO: Connection From: 203.0.113.7 on Node 1",
+	"2026-10-18 21:04:11 INFO: bob logged in on node 1",
+	"2026-10-18 21:04:12 INFO: bob loading menu menus/main.toml on node 1",
+	"2026-10-18 21:05:40 INFO: bob running door lord on node 1",
+	"2026-10-18 21:09:03 INFO: bob posting a message General on node 1",
+	"2026-10-18 21:10:27 INFO: Node 1 logged off",
+	"2026-10-18 21:11:00 INFO: New user signing up on node 2",
+	"2026-10-18 21:12:30 WARN: Bad password for bob on node 1",
+}
+
+func TestParseLine(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04:05", "2026-10-18 "+clock, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	want := []Event{
+		{Type: EventConnect, Time: at("21:04:02"), Node: "1", IP: "203.0.113.7"},
+		{Type: EventLogin, Time: at("21:04:11"), Node: "1", User: "bob"},
+		{Type: EventMenu, Time: at("21:04:12"), Node: "1", User: "bob", Location: "menus/main.toml"},
+		{Type: EventActivity, Time: at("21:05:40"), Node: "1", User: "bob", Action: actionDoor, Location: "lord"},
+		{Type: EventActivity, Time: at("21:09:03"), Node: "1", User: "bob", Action: actionPost, Location: "General"},
+		{Type: EventLogoff, Time: at("21:10:27"), Node: "1"},
+		{Type: EventNewUser, Time: at("21:11:00"), Node: "2"},
+		{Type: EventFailedLogin, Time: at("21:12:30"), Node: "1", User: "bob"},
+	}
+	for i, line := range sampleLog {
+		ev, ok := ParseLine(line)
+		if !ok {
+			t.Errorf("%q not parsed", line)
+			continue
+		}
+		if ev != want[i] {
+			t.Errorf("%q parsed as %+v, want %+v", line, ev, want[i])
+		}
+	}
+
+	if ev, ok := ParseLine("2026-10-18 21:12:00 INFO: Loaded 12 message areas"); ok {
+		t.Errorf("message areas line parsed as %+v, want no event", ev)
+	}
+}
+
+func TestParseFailedLoginPattern(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "wfc.ini")
+	err := os.WriteFile(configPath, []byte("[patterns]\n"+
+		`failedlogin = NOTICE: Login failed for (?P<user>.+?) on node (?P<node>\d+)`+"\n"+
+		`failedlogin = WARN: Bad password for user (?P<user>\S+) from \S+ on node (?P<node>\d+)`+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadWFCConfig(configPath, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		// Worded in a way the built-in pattern doesn't know
+		"2026-10-18 21:12:30 NOTICE: Login failed for bob on node 1",
+		// Known to the built-in pattern too, which would take the user as
+		// "bob from 203.0.113.7": the sysop's pattern wins
+		"2026-10-18 21:12:30 WARN: Bad password for user bob from 203.0.113.7 on node 1",
+		// Only the built-in pattern matches
+		"2026-10-18 21:12:30 WARN: Bad password for bob on node 1",
+	} {
+		ev, ok := config.Parser.Parse(line)
+		if !ok || ev.Type != EventFailedLogin || ev.User != "bob" || ev.Node != "1" {
+			t.Errorf("%q parsed as %+v, %v; want a failed login by bob on node 1", line, ev, ok)
+		}
+	}
+	// The built-in patterns still apply
+	if ev, ok := config.Parser.Parse(sampleLog[1]); !ok || ev.Type != EventLogin {
+		t.Errorf("%q parsed as %+v, %v; want a login", sampleLog[1], ev, ok)
+	}
+}
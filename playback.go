@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	// A modem sends 10 bits per character: start bit, 8 data bits and stop bit
+	bitsPerChar = 10
+
+	// How often paced output is flushed; fast rates send several characters
+	// per tick since sleeping per character isn't accurate enough
+	playbackTick = 10 * time.Millisecond
+)
+
+// SendAtBaud writes text to Output at the speed of a modem connection of baud
+// bits per second, or all at once when baud is 0. A key press on keys stops
+// it early, in which case it returns false. keys may be nil.
+func SendAtBaud(text string, baud int, keys <-chan byte) bool {
+	if baud <= 0 {
+		fmt.Fprint(Output, text)
+		return true
+	}
+
+	// Pace by character rather than byte, as text is UTF-8 decoded from CP437
+	chars := []rune(text)
+	charsPerSecond := float64(baud) / bitsPerChar
+	ticker := time.NewTicker(playbackTick)
+	defer ticker.Stop()
+
+	start := time.Now()
+	sent := 0
+	for sent < len(chars) {
+		select {
+		case <-keys:
+			return false
+		case now := <-ticker.C:
+			due := min(len(chars), int(now.Sub(start).Seconds()*charsPerSecond))
+			if due > sent {
+				fmt.Fprint(Output, string(chars[sent:due]))
+				sent = due
+			}
+		}
+	}
+	return true
+}
+
+// PlayAnsiFile clears the screen and plays a CP437 ANSI file, such as an
+// intro or an animation, at the given baud rate, then leaves it on screen for
+// hold. It returns false if a key press cut it short.
+func PlayAnsiFile(file string, baud int, hold time.Duration, keys <-chan byte) bool {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		log.Printf("Error reading file %s: %v", file, err)
+		return true
+	}
+	content, _ := ParseSauce(raw)
+	art, err := charmap.CodePage437.NewDecoder().String(TranslateColorCodes(string(content)))
+	if err != nil {
+		log.Printf("Error converting %s to UTF-8: %v", file, err)
+		return true
+	}
+
+	fmt.Fprint(Output, Reset)
+	ClearScreen()
+	if !SendAtBaud(art, baud, keys) {
+		return false
+	}
+	select {
+	case <-keys:
+		return false
+	case <-time.After(hold):
+		return true
+	}
+}
+
+// introPool returns the pool an intro is picked from at random, or nil when
+// file is empty.
+func introPool(file, artDir string) *ArtPool {
+	if file == "" {
+		return nil
+	}
+	return NewArtPool(Widget{File: file, Mode: artModeRandom}, artDir)
+}
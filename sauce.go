@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+const (
+	sauceRecordSize  = 128
+	sauceCommentSize = 64
+	sauceEOF         = 0x1A
+
+	// SAUCE data and file types for character based files
+	sauceDataCharacter = 1
+	sauceFileASCII     = 0
+	sauceFileANSi      = 1
+
+	// TFlags bit for iCE colors: blink selects a bright background instead
+	sauceFlagICEColors = 0x01
+)
+
+// Sauce is the metadata record appended to ANSI and ASCII art.
+type Sauce struct {
+	Title    string
+	Author   string
+	Group    string
+	Date     time.Time
+	FileSize int
+	DataType byte
+	FileType byte
+	Width    int // TInfo1, character width for character based files
+	Lines    int // TInfo2
+	Flags    byte
+	Font     string
+	Comments []string
+}
+
+// ICEColors reports whether the art uses iCE colors.
+func (s *Sauce) ICEColors() bool {
+	return s.Flags&sauceFlagICEColors != 0
+}
+
+// ParseSauce splits a file into its content and SAUCE record. The record is
+// nil when the file has none. The content always stops at the EOF marker, so
+// art containing the bytes "SAUCE00" or "COMNT" is left intact.
+func ParseSauce(data []byte) ([]byte, *Sauce) {
+	content := data
+	var sauce *Sauce
+
+	if len(data) >= sauceRecordSize {
+		record := data[len(data)-sauceRecordSize:]
+		if bytes.HasPrefix(record, []byte("SAUCE00")) {
+			sauce = &Sauce{
+				Title:    sauceString(record[7:42]),
+				Author:   sauceString(record[42:62]),
+				Group:    sauceString(record[62:82]),
+				FileSize: int(binary.LittleEndian.Uint32(record[90:94])),
+				DataType: record[94],
+				FileType: record[95],
+				Width:    int(binary.LittleEndian.Uint16(record[96:98])),
+				Lines:    int(binary.LittleEndian.Uint16(record[98:100])),
+				Flags:    record[105],
+				Font:     strings.TrimRight(string(record[106:128]), "\x00 "),
+			}
+			sauce.Date, _ = time.Parse("20060102", string(record[82:90]))
+			content = data[:len(data)-sauceRecordSize]
+
+			// An optional comment block sits right before the record
+			if comments := int(record[104]); comments > 0 {
+				blockSize := 5 + comments*sauceCommentSize
+				if len(content) >= blockSize && bytes.HasPrefix(content[len(content)-blockSize:], []byte("COMNT")) {
+					block := content[len(content)-blockSize+5:]
+					for i := 0; i < comments; i++ {
+						sauce.Comments = append(sauce.Comments, sauceString(block[i*sauceCommentSize:(i+1)*sauceCommentSize]))
+					}
+					content = content[:len(content)-blockSize]
+				}
+			}
+		}
+	}
+
+	if i := bytes.IndexByte(content, sauceEOF); i != -1 {
+		content = content[:i]
+	}
+	return content, sauce
+}
+
+// sauceString trims the space or NUL padding from a fixed width field.
+func sauceString(field []byte) string {
+	return strings.TrimRight(string(field), "\x00 ")
+}
+
+// Bytes encodes the record as an EOF marker, the comment block if there are
+// comments, and the 128 byte SAUCE block, ready to append to the file
+// contents. Text fields should already be CP437.
+func (s Sauce) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(sauceEOF)
+	comments := s.Comments
+	if len(comments) > 255 {
+		comments = comments[:255]
+	}
+	if len(comments) > 0 {
+		buf.WriteString("COMNT")
+		for _, comment := range comments {
+			buf.Write(sauceField(comment, sauceCommentSize))
+		}
+	}
+
+	buf.WriteString("SAUCE00")
+	buf.Write(sauceField(s.Title, 35))
+	buf.Write(sauceField(s.Author, 20))
+	buf.Write(sauceField(s.Group, 20))
+	buf.WriteString(s.Date.Format("20060102"))
+	binary.Write(&buf, binary.LittleEndian, uint32(s.FileSize))
+	buf.WriteByte(s.DataType)
+	buf.WriteByte(s.FileType)
+	binary.Write(&buf, binary.LittleEndian, uint16(s.Width))
+	binary.Write(&buf, binary.LittleEndian, uint16(s.Lines))
+	binary.Write(&buf, binary.LittleEndian, uint16(0)) // TInfo3
+	binary.Write(&buf, binary.LittleEndian, uint16(0)) // TInfo4
+	buf.WriteByte(byte(len(comments)))
+	buf.WriteByte(s.Flags)
+	font := []byte(s.Font)
+	if len(font) > 22 {
+		font = font[:22]
+	}
+	buf.Write(append(font, make([]byte, 22-len(font))...)) // TInfoS is zero padded
+	return buf.Bytes()
+}
+
+// sauceField pads or truncates a string field with spaces.
+func sauceField(s string, width int) []byte {
+	field := bytes.Repeat([]byte{' '}, width)
+	copy(field, s)
+	return field
+}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Screen draws the widgets of a layout from the current State.
+type Screen struct {
+	Layout     *Layout
+	Theme      *Theme
+	State      *State
+	Height     int
+	Width      int
+	SystemName string
+	ArtDir     string           // Directory relative art files are loaded from
+	Hint       string           // Key hint shown on the right of the footer
+	Started    time.Time        // When the WFC started, zero when not shown
+	Events     []ScheduledEvent // Upcoming events for the system panel
+	History    *History         // Caller history for the stats screen
+	View       string           // Screen shown instead of the WFC, if any
+	Period     int              // Index into leaderPeriods for the leaderboards
+	ShowGeo    bool             // Show where callers are from, when there's room
+	Hostnames  *Hostnames       // Reverse DNS for caller IPs, nil to show IPs
+	Detector   *Detector        // Flood and brute force detection for the suspicious panel
+	Bans       *Bans            // Ban list for the bans view
+	Covered    bool             // Something else, like connect art, is on screen; nothing is drawn
+
+	pools    map[string]*ArtPool // Art widget files, by widget name
+	heights  map[string]int      // Height each widget was last drawn at
+	flashing map[int]time.Time   // Node rows flashing for an alert, until when
+	flashOn  bool                // Flashing rows are currently highlighted
+
+	notice       string // Message shown in the footer in place of the hint
+	noticeUntil  time.Time
+	prompt       string // Question the footer asks in place of everything, if any
+	input        string // Answer typed so far
+	historyDrawn int    // Sessions in the history when a history view was drawn
+}
+
+// Views drawn in place of the WFC
+const (
+	viewWFC     = ""
+	viewStats   = "stats"
+	viewLeaders = "leaders"
+	viewDetails = "details"
+	viewBans    = "bans"
+)
+
+// Footer hints of each view
+var viewHints = map[string]string{
+	viewStats:   "S to return",
+	viewLeaders: "L for next period",
+	viewDetails: "D to return",
+	viewBans:    "A Ban  U Unban  B to return",
+}
+
+// How long a notice stays in the footer
+const noticeTime = 10 * time.Second
+
+// Draw clears the terminal and draws every widget.
+func (s *Screen) Draw() {
+	if s.Covered {
+		return
+	}
+	fmt.Fprint(Output, Reset+s.Theme.Background)
+	ClearScreen()
+	s.State.Dirty() // Everything is about to be drawn
+	switch s.View {
+	case viewStats:
+		s.drawStatsScreen()
+	case viewLeaders:
+		s.drawLeaderScreen()
+	case viewDetails:
+		s.drawDetailScreen()
+	case viewBans:
+		s.drawBanScreen()
+	}
+	if s.View != viewWFC {
+		s.drawFooter(Rect{X: 1, Y: s.Height})
+		return
+	}
+	for _, widget := range s.Layout.Widgets {
+		s.drawWidget(widget)
+	}
+}
+
+// Refresh redraws the parts of the screen that changed since the last call.
+// Node rows are only redrawn when their node changed; the clock and system
+// panel always tick. A change of art redraws everything, as widgets below it
+// may move.
+func (s *Screen) Refresh() {
+	if s.Covered {
+		return
+	}
+	now := time.Now()
+	if s.View == viewDetails || s.View == viewBans {
+		// Only callers coming and going change these views
+		if len(s.State.Dirty()) > 0 {
+			s.Draw()
+		}
+		return
+	}
+	if s.View != viewWFC {
+		// Only new calls change the history views
+		if len(s.History.Sessions()) != s.historyDrawn {
+			s.Draw()
+		}
+		return
+	}
+	for _, widget := range s.Layout.Widgets {
+		if widget.Type == "art" && s.pool(widget).Due(now) {
+			s.Draw()
+			return
+		}
+	}
+
+	dirty := s.State.Dirty()
+	flashing := s.flash(now)
+	noticeExpired := s.notice != "" && now.After(s.noticeUntil)
+	if noticeExpired {
+		s.notice = ""
+	}
+	for _, widget := range s.Layout.Widgets {
+		switch widget.Type {
+		case "art":
+			// Static
+		case "clock", "system", "counters", "suspicious":
+			s.drawWidget(widget)
+		case "footer":
+			if len(dirty) > 0 || noticeExpired {
+				s.drawWidget(widget)
+			}
+		case "nodes":
+			rect := s.rect(widget)
+			for _, nodeNum := range dirty {
+				s.drawNodeRow(rect, nodeNum)
+			}
+			for _, nodeNum := range flashing {
+				s.drawNodeRow(rect, nodeNum)
+			}
+		default:
+			if len(dirty) > 0 {
+				s.drawWidget(widget)
+			}
+		}
+	}
+}
+
+// Notify shows a message in the footer for a while.
+func (s *Screen) Notify(text string) {
+	s.notice, s.noticeUntil = text, time.Now().Add(noticeTime)
+	s.redrawFooter()
+}
+
+// Prompt asks a question in the footer. Keys go to Type until it's answered.
+func (s *Screen) Prompt(question string) {
+	s.prompt, s.input = question, ""
+	s.redrawFooter()
+}
+
+// Prompting reports whether the footer is waiting for an answer.
+func (s *Screen) Prompting() bool {
+	return s.prompt != ""
+}
+
+// Type adds a key to the answer. Enter returns the answer with done set,
+// escape returns it empty.
+func (s *Screen) Type(key byte) (answer string, done bool) {
+	switch {
+	case key == '\r' || key == '\n':
+		answer, done = strings.TrimSpace(s.input), true
+	case key == 27: // Escape
+		done = true
+	case key == 8 || key == 127: // Backspace
+		if len(s.input) > 0 {
+			s.input = s.input[:len(s.input)-1]
+		}
+	case key >= ' ' && key <= '~':
+		s.input += string(key)
+	}
+	if done {
+		s.prompt, s.input = "", ""
+	}
+	s.redrawFooter()
+	return answer, done
+}
+
+// redrawFooter draws the footer widget, or the footer line of a view.
+func (s *Screen) redrawFooter() {
+	if s.Covered {
+		return
+	}
+	if s.View != viewWFC {
+		s.drawFooter(Rect{X: 1, Y: s.Height})
+		return
+	}
+	for _, widget := range s.Layout.Widgets {
+		if widget.Type == "footer" {
+			s.drawWidget(widget)
+		}
+	}
+}
+
+// Flash makes a node row flash until the given time.
+func (s *Screen) Flash(nodeNum int, until time.Time) {
+	if s.flashing == nil {
+		s.flashing = make(map[int]time.Time)
+	}
+	s.flashing[nodeNum] = until
+}
+
+// flash toggles the highlight of flashing rows and returns the rows that need
+// redrawing, including ones that just stopped.
+func (s *Screen) flash(now time.Time) []int {
+	s.flashOn = !s.flashOn
+	var rows []int
+	for nodeNum, until := range s.flashing {
+		if now.After(until) {
+			delete(s.flashing, nodeNum)
+		}
+		rows = append(rows, nodeNum)
+	}
+	return rows
+}
+
+// rect resolves a widget's position, placing it right under the widget
+// named by its Below setting when it has one.
+func (s *Screen) rect(widget Widget) Rect {
+	rect := widget.Rect(s.Height, s.Width)
+	if widget.Below != "" {
+		above := s.Layout.Widget(widget.Below)
+		rect.Y = s.rect(*above).Y + s.heights[above.Name]
+	}
+	return rect
+}
+
+// pool returns the art pool of an art widget.
+func (s *Screen) pool(widget Widget) *ArtPool {
+	if s.pools == nil {
+		s.pools = make(map[string]*ArtPool)
+	}
+	if _, exists := s.pools[widget.Name]; !exists {
+		s.pools[widget.Name] = NewArtPool(widget, s.ArtDir)
+	}
+	return s.pools[widget.Name]
+}
+
+// drawWidget draws a widget and records its height for widgets placed below it.
+func (s *Screen) drawWidget(widget Widget) {
+	if s.heights == nil {
+		s.heights = make(map[string]int)
+	}
+
+	rect := s.rect(widget)
+	height := 0
+	switch widget.Type {
+	case "art":
+		pool := s.pool(widget)
+		file := pool.current
+		if now := time.Now(); pool.Due(now) {
+			file = pool.Pick(now)
+		}
+		height = s.drawArt(rect, file)
+	case "nodes":
+		height = s.drawNodes(rect)
+	case "lastcallers":
+		height = s.drawLastCallers(rect)
+	case "stats":
+		height = s.drawStats(rect)
+	case "clock":
+		height = s.drawClock(rect)
+	case "system":
+		height = s.drawSystem(rect)
+	case "counters":
+		height = s.drawCounters(rect)
+	case "suspicious":
+		height = s.drawSuspects(rect)
+	case "footer":
+		height = s.drawFooter(rect)
+	}
+	s.heights[widget.Name] = height
+}
+
+// drawArt draws an art file and returns the number of rows it took.
+func (s *Screen) drawArt(rect Rect, file string) int {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		log.Printf("Error reading file %s: %v", file, err)
+		return 0
+	}
+
+	// Use the SAUCE width and iCE colors flag when the art has them
+	content, sauce := ParseSauce(raw)
+	width, iceColors := 80, false
+	if sauce != nil && sauce.DataType == sauceDataCharacter {
+		if sauce.Width > 0 {
+			width = sauce.Width
+		}
+		iceColors = sauce.ICEColors()
+	}
+	art, err := charmap.CodePage437.NewDecoder().String(TranslateColorCodes(string(content)))
+	if err != nil {
+		log.Printf("Error converting %s to UTF-8: %v", file, err)
+		return 0
+	}
+
+	// Render into a virtual screen first so the art can't draw outside its widget
+	canvas := RenderAnsi(art, width, iceColors)
+	blitWidth, blitHeight := rect.W, rect.H
+	if blitWidth == 0 {
+		blitWidth = canvas.Width
+	}
+	if blitHeight == 0 {
+		blitHeight = canvas.Height()
+	}
+	blitWidth = min(blitWidth, s.Width-rect.X+1)
+	blitHeight = min(blitHeight, s.Height-rect.Y+1)
+	canvas.Blit(rect.X, rect.Y, 0, 0, blitWidth, blitHeight, s.Theme.Background)
+	return blitHeight
+}
+
+func formatCell(text string, width int, color string) string {
+	return Reset + color + PadOrTruncate(text, width) + Reset
+}
+
+// drawNodes draws the table headers and a row for every node.
+func (s *Screen) drawNodes(rect Rect) int {
+	// Draw table headers with colors
+	MoveCursor(rect.X, rect.Y)
+	fmt.Fprint(Output,
+		s.Theme.Background+" "+
+			formatCell("Node", nodeColWidth, s.Theme.NodeLabel)+
+			formatCell("User", userColWidth, s.Theme.UserLabel)+
+			formatCell("Location", locationColWidth, s.Theme.LocationLabel),
+	)
+	tableWidth := totalTableWidth
+	if s.showGeoColumn(rect) {
+		fmt.Fprint(Output, formatCell("From", geoColWidth, s.Theme.LocationLabel))
+		tableWidth += geoColWidth
+	}
+	MoveCursor(rect.X, rect.Y+1)
+	fmt.Fprint(Output, s.Theme.Background+" "+strings.Repeat(s.Theme.Separator+"-", tableWidth)+Reset)
+
+	for i := 1; i <= s.State.MaxNodes; i++ {
+		s.drawNodeRow(rect, i)
+	}
+	if rect.H > 0 {
+		return min(rect.H, 2+s.State.MaxNodes)
+	}
+	return 2 + s.State.MaxNodes
+}
+
+// drawNodeRow draws a single row in the node table, unless the row falls
+// outside the widget's height.
+func (s *Screen) drawNodeRow(rect Rect, nodeNum int) {
+	if rect.H > 0 && nodeNum > rect.H-2 {
+		return
+	}
+	status := s.State.Node(nodeNum)
+
+	// Determine color based on user status
+	nodeColor, userColor, locationColor := s.Theme.Node, s.Theme.User, s.Theme.Location
+	if status.User == waitingUser {
+		userColor = s.Theme.UserWaiting // Default color for "waiting for caller"
+	}
+	if _, flashing := s.flashing[nodeNum]; flashing && s.flashOn {
+		nodeColor, userColor, locationColor = nodeColor+Reverse, userColor+Reverse, locationColor+Reverse
+	}
+
+	// Until the caller gets further, the location is their address
+	location := status.Location
+	if status.IP != "" && location == status.IP {
+		location = s.address(status.IP)
+	}
+
+	// Format and print the node data
+	MoveCursor(rect.X, rect.Y+1+nodeNum)
+	fmt.Fprint(Output,
+		s.Theme.Background+" "+
+			formatCell(strconv.Itoa(nodeNum), nodeColWidth, nodeColor)+
+			formatCell(status.User, userColWidth, userColor)+
+			formatCell(location, locationColWidth, locationColor),
+	)
+	if s.showGeoColumn(rect) {
+		from := status.Geo
+		if from == "" {
+			from = status.Network
+		}
+		fmt.Fprint(Output, formatCell(from, geoColWidth, locationColor))
+	}
+}
+
+// showGeoColumn reports whether the node table has a From column: when GeoIP
+// is set up and the widget, or the screen right of it, is wide enough.
+func (s *Screen) showGeoColumn(rect Rect) bool {
+	width := rect.W
+	if width == 0 {
+		width = s.Width - rect.X + 1
+	}
+	return s.ShowGeo && width >= 1+totalTableWidth+geoColWidth
+}
+
+// drawLastCallers lists the most recent logins and failed attempts under a
+// title line.
+func (s *Screen) drawLastCallers(rect Rect) int {
+	width, height := rect.W, rect.H
+	if width == 0 {
+		width = 40
+	}
+	if height == 0 {
+		height = 6
+	}
+
+	MoveCursor(rect.X, rect.Y)
+	fmt.Fprint(Output, formatCell(" Last Callers", width, s.Theme.LastUserLabel))
+	calls := s.State.RecentAttempts(height - 1)
+	for i := 0; i < height-1; i++ {
+		line, color := "", s.Theme.LastUser
+		if i < len(calls) {
+			call := calls[i]
+			line = fmt.Sprintf(" %s  %-20s node %s", call.Time.Format("15:04"), call.User, call.Node)
+			if call.Failure != "" {
+				// Failures often have no user, the address says who it was
+				who := call.User
+				if who == "" {
+					who = s.address(call.IP)
+				}
+				line = fmt.Sprintf(" %s  %-20s %s", call.Time.Format("15:04"), PadOrTruncate(who, 20), call.Failure)
+				color = s.Theme.UserWaiting
+			}
+		}
+		MoveCursor(rect.X, rect.Y+1+i)
+		fmt.Fprint(Output, formatCell(line, width, color))
+	}
+	return height
+}
+
+// drawStats prints the last user, today's calls and today's bots and
+// failed logins.
+func (s *Screen) drawStats(rect Rect) int {
+	width := rect.W
+	if width == 0 {
+		width = s.Width - rect.X + 1
+	}
+	today := s.State.TodayStats()
+	lines := []struct{ label, value string }{
+		{" Last User:", " " + s.State.LastUser},
+		{" Today's Calls:", fmt.Sprintf(" %d (excluding %s)", today.Calls, excludeUser)},
+		{" Bots Today:", fmt.Sprintf(" %d  Failed: %d no login, %d bad password, %d abandoned", today.Bots, today.Failed, today.BadPasswords, today.Abandoned)},
+	}
+	for i, line := range lines {
+		MoveCursor(rect.X, rect.Y+i)
+		fmt.Fprint(Output,
+			formatCell(line.label, len(line.label), s.Theme.LastUserLabel)+
+				formatCell(line.value, max(0, width-len(line.label)), s.Theme.LastUser),
+		)
+	}
+	return len(lines)
+}
+
+// drawClock shows the current time and date.
+func (s *Screen) drawClock(rect Rect) int {
+	width := rect.W
+	if width == 0 {
+		width = 22
+	}
+	MoveCursor(rect.X, rect.Y)
+	fmt.Fprint(Output, formatCell(time.Now().Format(" 15:04:05  Mon Jan 02"), width, s.Theme.LastUser))
+	return 1
+}
+
+// drawSystem shows WFC and host uptime, the load average and the next
+// scheduled event. Lines the system can't provide are left out.
+func (s *Screen) drawSystem(rect Rect) int {
+	width := rect.W
+	if width == 0 {
+		width = 40
+	}
+	now := time.Now()
+	var lines []struct{ label, value string }
+	add := func(label, value string) {
+		lines = append(lines, struct{ label, value string }{label, value})
+	}
+	if !s.Started.IsZero() {
+		add(" WFC Up:", " "+formatUptime(now.Sub(s.Started)))
+	}
+	if uptime, ok := hostUptime(); ok {
+		add(" Host Up:", " "+formatUptime(uptime))
+	}
+	if load, ok := loadAverage(); ok {
+		add(" Load:", " "+load)
+	}
+	if event, at, ok := nextEvent(s.Events, now); ok {
+		add(" Next Event:", fmt.Sprintf(" %s %s (in %s)", at.Format("15:04"), event.Name, formatUptime(at.Sub(now))))
+	}
+
+	for i, line := range lines {
+		MoveCursor(rect.X, rect.Y+i)
+		fmt.Fprint(Output,
+			formatCell(line.label, len(line.label), s.Theme.LastUserLabel)+
+				formatCell(line.value, max(0, width-len(line.label)), s.Theme.LastUser),
+		)
+	}
+	return len(lines)
+}
+
+// drawCounters shows the counters kept by scripts.
+func (s *Screen) drawCounters(rect Rect) int {
+	width := rect.W
+	if width == 0 {
+		width = 40
+	}
+	for i, counter := range s.State.Counters {
+		if rect.H > 0 && i >= rect.H {
+			break
+		}
+		label := " " + counter.Label + ":"
+		MoveCursor(rect.X, rect.Y+i)
+		fmt.Fprint(Output,
+			formatCell(label, len(label), s.Theme.LastUserLabel)+
+				formatCell(" "+strconv.FormatFloat(counter.Value, 'f', -1, 64), max(0, width-len(label)), s.Theme.LastUser),
+		)
+	}
+	if rect.H > 0 {
+		return min(rect.H, len(s.State.Counters))
+	}
+	return len(s.State.Counters)
+}
+
+// drawFooter draws the bar with the system name and a key hint on the right.
+func (s *Screen) drawFooter(rect Rect) int {
+	width := rect.W
+	if width == 0 {
+		width = s.Width - rect.X + 1
+	}
+	MoveCursor(rect.X, rect.Y)
+	PrintSpaces(width, s.Theme.Footer)
+
+	MoveCursor(rect.X, rect.Y)
+	if s.prompt != "" {
+		fmt.Fprint(Output, s.Theme.Footer+s.Theme.FooterLabel+PadOrTruncate(" "+s.prompt+s.input+"_", width)+Reset)
+		return 1
+	}
+	// The name is shown in the footer colors, like in bulletins
+	fmt.Fprint(Output, s.Theme.Footer+s.Theme.FooterLabel+" System Name: "+StripColorCodes(s.SystemName)+Reset)
+	hint := TranslateColorCodes(s.Hint)
+	if s.View != viewWFC {
+		hint = viewHints[s.View]
+	}
+	if s.notice != "" {
+		hint = TranslateColorCodes(s.notice)
+	}
+	MoveCursor(rect.X+width-1-len(StripAnsi(hint)), rect.Y)
+	fmt.Fprint(Output, s.Theme.Footer+s.Theme.FooterLabel+hint+Reset)
+	return 1
+}
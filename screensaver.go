@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Screensaver effects
+const (
+	saverStarfield = "starfield"
+	saverMatrix    = "matrix"
+	saverArt       = "art"
+
+	// Time between animation frames
+	saverFrameInterval = 80 * time.Millisecond
+
+	numStars     = 60
+	matrixLength = 12 // Trail length of a falling column
+)
+
+type star struct {
+	x, y, z float64
+	sx, sy  int // Last drawn position, 0 when not drawn
+}
+
+// Screensaver takes over the screen after a period without log events or
+// key presses, to keep a WFC that's left on for days from burning in.
+type Screensaver struct {
+	config       ScreensaverConfig
+	screen       *Screen
+	active       bool
+	lastActivity time.Time
+
+	stars []star
+	drops []int // Head row of the falling column in each screen column
+	pool  *ArtPool
+}
+
+// NewScreensaver creates a screensaver drawing over screen.
+func NewScreensaver(config ScreensaverConfig, screen *Screen) *Screensaver {
+	saver := &Screensaver{config: config, screen: screen, lastActivity: time.Now()}
+	if config.Mode == saverArt {
+		saver.pool = NewArtPool(Widget{File: config.Art, Mode: artModeRandom, Interval: config.Interval}, screen.ArtDir)
+	}
+	return saver
+}
+
+// Active reports whether the screensaver is showing.
+func (s *Screensaver) Active() bool {
+	return s.active
+}
+
+// Activity resets the idle timer. It returns true when the screensaver was
+// showing, in which case it has stopped and the WFC screen must be redrawn.
+func (s *Screensaver) Activity() bool {
+	s.lastActivity = time.Now()
+	if !s.active {
+		return false
+	}
+	s.active = false
+	return true
+}
+
+// Tick starts the screensaver once the WFC has been idle long enough and
+// draws the next frame while it's showing.
+func (s *Screensaver) Tick(now time.Time) {
+	if !s.active {
+		if now.Sub(s.lastActivity) < s.config.Idle {
+			return
+		}
+		s.start()
+	}
+
+	switch s.config.Mode {
+	case saverMatrix:
+		s.matrixFrame()
+	case saverArt:
+		if s.pool.Due(now) {
+			fmt.Fprint(Output, Reset)
+			ClearScreen()
+			rect := Rect{X: 1, Y: 1, W: s.screen.Width, H: s.screen.Height}
+			s.screen.drawArt(rect, s.pool.Pick(now))
+		}
+	default:
+		s.starfieldFrame()
+	}
+}
+
+func (s *Screensaver) start() {
+	s.active = true
+	fmt.Fprint(Output, Reset)
+	ClearScreen()
+
+	s.stars = make([]star, numStars)
+	for i := range s.stars {
+		s.stars[i] = newStar()
+	}
+	s.drops = make([]int, s.screen.Width)
+	for i := range s.drops {
+		s.drops[i] = -rand.Intn(max(1, s.screen.Height))
+	}
+	if s.pool != nil {
+		s.pool.current = "" // Show new art straight away
+	}
+}
+
+func newStar() star {
+	return star{
+		x: rand.Float64()*2 - 1,
+		y: rand.Float64()*2 - 1,
+		z: rand.Float64()*0.9 + 0.1,
+	}
+}
+
+// starfieldFrame moves every star towards the viewer, drawing nearer stars
+// brighter.
+func (s *Screensaver) starfieldFrame() {
+	w, h := s.screen.Width, s.screen.Height
+	for i := range s.stars {
+		st := &s.stars[i]
+		if st.sx > 0 {
+			MoveCursor(st.sx, st.sy)
+			fmt.Fprint(Output, " ")
+		}
+
+		st.z -= 0.02
+		sx := w/2 + int(st.x/st.z*float64(w)/4)
+		sy := h/2 + int(st.y/st.z*float64(h)/4)
+		if st.z <= 0.01 || sx < 1 || sx > w || sy < 1 || sy > h {
+			*st = newStar()
+			continue
+		}
+
+		glyph := BlackHi + "."
+		switch {
+		case st.z < 0.3:
+			glyph = WhiteHi + "*"
+		case st.z < 0.6:
+			glyph = White + "+"
+		}
+		MoveCursor(sx, sy)
+		fmt.Fprint(Output, glyph+Reset)
+		st.sx, st.sy = sx, sy
+	}
+}
+
+// matrixFrame advances the falling columns of characters.
+func (s *Screensaver) matrixFrame() {
+	h := s.screen.Height
+	for col := range s.drops {
+		// Only move a few columns each frame so they fall at different speeds
+		if rand.Intn(3) != 0 {
+			continue
+		}
+		head := s.drops[col]
+		if head >= 1 && head <= h {
+			MoveCursor(col+1, head)
+			fmt.Fprint(Output, GreenHi+string(rune('!'+rand.Intn(94)))+Reset)
+		}
+		if head-1 >= 1 && head-1 <= h {
+			MoveCursor(col+1, head-1)
+			fmt.Fprint(Output, Green+string(rune('!'+rand.Intn(94)))+Reset)
+		}
+		if tail := head - matrixLength; tail >= 1 && tail <= h {
+			MoveCursor(col+1, tail)
+			fmt.Fprint(Output, " ")
+		}
+
+		s.drops[col]++
+		if s.drops[col]-matrixLength > h {
+			s.drops[col] = -rand.Intn(max(1, h))
+		}
+	}
+}
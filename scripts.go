@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Longest a script may run for one event, line or file load before it's
+// stopped, so a script stuck in a loop can't freeze the WFC
+const scriptTimeout = time.Second
+
+// scriptPattern is a log pattern added by a script.
+type scriptPattern struct {
+	pattern *regexp.Regexp
+	handler *lua.LFunction
+}
+
+// Scripts runs the sysop's Lua scripts. Scripts register handlers through
+// the "wfc" table:
+//
+//	wfc.on(event, function(ev) end)       -- connect, login, newuser, logoff, door, post, failedlogin or "*"
+//	wfc.pattern(regex, function(m) end)   -- m holds the named captures and the line
+//	wfc.counter(name, label)              -- show a counter on the counters widget
+//	wfc.add(name[, n]) / wfc.set(name, n) / wfc.get(name)
+//	wfc.notify(text)                      -- show a message in the footer
+//
+// Everything runs on the event loop, so scripts never run concurrently.
+type Scripts struct {
+	L        *lua.LState
+	screen   *Screen
+	handlers map[string][]*lua.LFunction
+	patterns []scriptPattern
+}
+
+// LoadScripts runs every .lua file in dir, in name order, to register their
+// handlers. An empty dir loads nothing.
+func LoadScripts(dir string, screen *Screen) (*Scripts, error) {
+	s := &Scripts{L: lua.NewState(), screen: screen, handlers: make(map[string][]*lua.LFunction)}
+	s.L.SetGlobal("wfc", s.L.SetFuncs(s.L.NewTable(), map[string]lua.LGFunction{
+		"on":      s.luaOn,
+		"pattern": s.luaPattern,
+		"counter": s.luaCounter,
+		"add":     s.luaAdd,
+		"set":     s.luaSet,
+		"get":     s.luaGet,
+		"notify":  s.luaNotify,
+	}))
+	if dir == "" {
+		return s, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if err := s.limited(func() error { return s.L.DoFile(file) }); err != nil {
+			return nil, fmt.Errorf("script %s: %w", file, err)
+		}
+	}
+	return s, nil
+}
+
+// Close shuts down the Lua interpreter.
+func (s *Scripts) Close() {
+	s.L.Close()
+}
+
+// Line runs the handlers of script patterns matching a raw log line. live is
+// false while the log is read back on startup.
+func (s *Scripts) Line(line string, live bool) {
+	for _, p := range s.patterns {
+		matches := p.pattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		m := s.L.NewTable()
+		for i, name := range p.pattern.SubexpNames() {
+			if name != "" {
+				m.RawSetString(name, lua.LString(matches[i]))
+			}
+		}
+		m.RawSetString("line", lua.LString(line))
+		m.RawSetString("live", lua.LBool(live))
+		s.call(p.handler, m)
+	}
+}
+
+// Event runs the handlers registered for an event.
+func (s *Scripts) Event(ev Event, live bool) {
+	name := hookEventName(ev)
+	if name == "" {
+		return
+	}
+	var handlers []*lua.LFunction
+	handlers = append(handlers, s.handlers[name]...)
+	handlers = append(handlers, s.handlers["*"]...)
+	if len(handlers) == 0 {
+		return
+	}
+
+	t := s.L.NewTable()
+	for key, value := range map[string]string{
+		"event":    name,
+		"time":     ev.Time.Format("2006-01-02 15:04:05"),
+		"node":     ev.Node,
+		"user":     ev.User,
+		"ip":       ev.IP,
+		"action":   ev.Action,
+		"location": ev.Location,
+		"label":    ev.Label,
+	} {
+		t.RawSetString(key, lua.LString(value))
+	}
+	t.RawSetString("live", lua.LBool(live))
+	for _, handler := range handlers {
+		s.call(handler, t)
+	}
+}
+
+// call runs a handler, logging errors so a broken script can't stop the WFC.
+func (s *Scripts) call(handler *lua.LFunction, arg lua.LValue) {
+	err := s.limited(func() error {
+		return s.L.CallByParam(lua.P{Fn: handler, NRet: 0, Protect: true}, arg)
+	})
+	if err != nil {
+		log.Printf("Script error: %v", err)
+	}
+}
+
+// limited runs fn with the interpreter stopped once scriptTimeout has passed.
+func (s *Scripts) limited(fn func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeout)
+	defer cancel()
+	s.L.SetContext(ctx)
+	defer s.L.RemoveContext()
+	err := fn()
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("stopped after running for %v: %w", scriptTimeout, err)
+	}
+	return err
+}
+
+func (s *Scripts) luaOn(L *lua.LState) int {
+	event := L.CheckString(1)
+	valid := event == "*"
+	for _, name := range hookEvents {
+		valid = valid || name == event
+	}
+	if !valid {
+		L.ArgError(1, "unknown event "+event)
+	}
+	s.handlers[event] = append(s.handlers[event], L.CheckFunction(2))
+	return 0
+}
+
+func (s *Scripts) luaPattern(L *lua.LState) int {
+	pattern, err := regexp.Compile(L.CheckString(1))
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+	s.patterns = append(s.patterns, scriptPattern{pattern: pattern, handler: L.CheckFunction(2)})
+	return 0
+}
+
+func (s *Scripts) luaCounter(L *lua.LState) int {
+	counter := s.screen.State.Counter(L.CheckString(1))
+	counter.Label = L.OptString(2, counter.Name)
+	return 0
+}
+
+func (s *Scripts) luaAdd(L *lua.LState) int {
+	counter := s.screen.State.Counter(L.CheckString(1))
+	counter.Value += float64(L.OptNumber(2, 1))
+	return 0
+}
+
+func (s *Scripts) luaSet(L *lua.LState) int {
+	s.screen.State.Counter(L.CheckString(1)).Value = float64(L.CheckNumber(2))
+	return 0
+}
+
+func (s *Scripts) luaGet(L *lua.LState) int {
+	L.Push(lua.LNumber(s.screen.State.Counter(L.CheckString(1)).Value))
+	return 1
+}
+
+func (s *Scripts) luaNotify(L *lua.LState) int {
+	s.screen.Notify(L.CheckString(1))
+	return 0
+}
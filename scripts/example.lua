@@ -0,0 +1,32 @@
+-- Example talisman-wfc script. Point [scripts] dir in wfc.ini at a directory
+-- of .lua files to load them. Add a [counters] widget to your layout to see
+-- the counters.
+--
+-- Handlers get ev.live = false for events read back from the log on startup,
+-- so counters catch up on today's activity without repeating notifications.
+
+wfc.counter("lord", "LORD plays")
+wfc.counter("chats", "Sysop chats")
+
+-- Count plays of one door
+wfc.on("door", function(ev)
+  if ev.location:lower():find("lord") then
+    wfc.add("lord")
+  end
+end)
+
+-- Say hello when a regular logs in
+local regulars = { bob = true, alice = true }
+wfc.on("login", function(ev)
+  if ev.live and regulars[ev.user:lower()] then
+    wfc.notify(ev.user .. " is on node " .. ev.node)
+  end
+end)
+
+-- Lines the WFC doesn't know about can be matched with named captures
+wfc.pattern("INFO: (?P<user>.+?) paged the sysop on node (?P<node>\\d+)", function(m)
+  wfc.add("chats")
+  if m.live then
+    wfc.notify(m.user .. " is paging you!")
+  end
+end)
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	waitingUser     = "waiting for caller"
+	waitingLocation = "-"
+	unknownUser     = "Unknown User" // Connected, not logged in yet
+
+	// Number of recent logins kept for last callers lists
+	maxRecentCalls = 50
+)
+
+type NodeStatus struct {
+	User     string
+	Location string
+	IP       string    // Caller's IP, kept for the whole call
+	Geo      string    // Where the IP is
+	Network  string    // Autonomous system the IP belongs to
+	Since    time.Time // When the call started, zero when idle
+
+	shown bool // An unknown caller has been connected long enough to show
+}
+
+// Ways a call can fail, as shown in last callers lists
+const (
+	failureNoLogin     = "no login"
+	failureBadPassword = "bad password"
+	failureAbandoned   = "abandoned signup"
+)
+
+// Call is a single login, or a failed attempt, as shown in last callers lists.
+type Call struct {
+	User    string
+	Node    string
+	Time    time.Time
+	IP      string // Set for failures
+	Failure string // How the attempt failed, empty for logins
+}
+
+// DayStats counts the activity seen on one day.
+type DayStats struct {
+	Date         string // YYYY-MM-DD
+	Calls        int
+	NewUsers     int
+	Posts        int
+	Doors        int
+	Bots         int // Connections dropped before the login prompt
+	Failed       int // Connections that got the prompt but never logged in
+	BadPasswords int // Bad passwords logged
+	Abandoned    int // New users who left before finishing signing up
+}
+
+// Counter is a custom stat kept by a script and shown on the counters widget.
+type Counter struct {
+	Name  string
+	Label string
+	Value float64
+}
+
+// State tracks who is on each node as log events are applied to it. The WFC
+// screen and door mode both build their view of the board from a State.
+type State struct {
+	MaxNodes int
+	BotTime  time.Duration // Connections dropped sooner without logging in are bots
+	LastUser string
+	Calls    []Call // Most recent last, excluding excludeUser
+	Failures []Call // Failed attempts, most recent last
+	Today    DayStats
+	Counters []Counter // In the order scripts defined them
+
+	nodes       map[string]NodeStatus
+	activeUsers map[string]string // node number to username mapping
+	dirty       map[int]bool      // nodes changed since the last redraw
+	latest      time.Time         // Time of the latest event applied
+	clock       func() time.Time  // time.Now, or a stand in
+}
+
+// NewState creates an empty State for a board with maxNodes nodes.
+func NewState(maxNodes int, lastUser string) *State {
+	return &State{
+		MaxNodes:    maxNodes,
+		LastUser:    lastUser,
+		nodes:       make(map[string]NodeStatus, maxNodes),
+		activeUsers: make(map[string]string),
+		dirty:       make(map[int]bool),
+		clock:       time.Now,
+	}
+}
+
+// Apply updates the node table and counters with a parsed log event.
+func (s *State) Apply(ev Event) {
+	if ev.Time.After(s.latest) {
+		s.latest = ev.Time
+	}
+	s.count(ev)
+
+	// The caller's IP and where it's from stay with the node until logoff
+	status, exists := s.nodes[ev.Node]
+	if !exists {
+		status.Since = ev.Time
+	}
+	switch ev.Type {
+	case EventConnect:
+		// "Unknown User" in the User column and IP in the Location column
+		s.nodes[ev.Node] = NodeStatus{User: unknownUser, Location: ev.IP, IP: ev.IP, Geo: ev.Geo, Network: ev.Network, Since: ev.Time}
+	case EventLogin:
+		if _, loggedIn := s.activeUsers[ev.Node]; loggedIn {
+			// A new call whose connection wasn't logged
+			status = NodeStatus{Since: ev.Time}
+		}
+		// Set the user and display "logging in..." in the Location column
+		status.User, status.Location = ev.User, "logging in..."
+		s.nodes[ev.Node] = status
+		s.activeUsers[ev.Node] = ev.User
+	case EventNewUser:
+		// Do not add "New User" to activeUsers since it's not the actual username
+		status.User, status.Location = "New User", "Signing up..."
+		s.nodes[ev.Node] = status
+	case EventMenu, EventActivity:
+		status.User, status.Location = ev.User, describeLocation(ev)
+		s.nodes[ev.Node] = status
+	case EventLogoff:
+		// Ensure we only update LastUser if there was an actual user logged in
+		if user, exists := s.activeUsers[ev.Node]; exists && user != "New User" {
+			s.LastUser = user
+		}
+		delete(s.activeUsers, ev.Node)
+		delete(s.nodes, ev.Node)
+	case EventFailedLogin:
+		// The node is unchanged, but redrawing it redraws the last callers
+	default:
+		return
+	}
+
+	if nodeNum, err := strconv.Atoi(ev.Node); err == nil {
+		s.dirty[nodeNum] = true
+	}
+}
+
+// count updates the recent calls list and the day's counters.
+func (s *State) count(ev Event) {
+	day := ev.Time.Format("2006-01-02")
+	if day < s.Today.Date {
+		return
+	}
+	if day != s.Today.Date {
+		s.Today = DayStats{Date: day}
+	}
+
+	switch ev.Type {
+	case EventLogin:
+		if ev.User == excludeUser {
+			return
+		}
+		s.Today.Calls++
+		s.Calls = append(s.Calls, Call{User: ev.User, Node: ev.Node, Time: ev.Time})
+		if len(s.Calls) > maxRecentCalls {
+			s.Calls = s.Calls[len(s.Calls)-maxRecentCalls:]
+		}
+	case EventNewUser:
+		s.Today.NewUsers++
+	case EventLogoff:
+		// The node still shows the call that just ended
+		status, exists := s.nodes[ev.Node]
+		if _, loggedIn := s.activeUsers[ev.Node]; !exists || loggedIn {
+			break
+		}
+		switch {
+		case status.User == "New User":
+			s.Today.Abandoned++
+			s.fail(ev, status.IP, failureAbandoned)
+		case status.User != unknownUser:
+			// Logged in before the log was rotated
+		case s.bot(status, ev.Time):
+			s.Today.Bots++
+		default:
+			s.Today.Failed++
+			s.fail(ev, status.IP, failureNoLogin)
+		}
+	case EventFailedLogin:
+		s.Today.BadPasswords++
+		s.fail(ev, s.nodes[ev.Node].IP, failureBadPassword)
+	case EventActivity:
+		switch ev.Action {
+		case actionPost:
+			s.Today.Posts++
+		case actionDoor:
+			s.Today.Doors++
+		}
+	}
+}
+
+// fail adds a failed attempt to the last callers list.
+func (s *State) fail(ev Event, ip, failure string) {
+	s.Failures = append(s.Failures, Call{User: ev.User, Node: ev.Node, Time: ev.Time, IP: ip, Failure: failure})
+	if len(s.Failures) > maxRecentCalls {
+		s.Failures = s.Failures[len(s.Failures)-maxRecentCalls:]
+	}
+}
+
+// TodayStats returns the counters for the current day, which are empty when
+// nothing has been logged yet today.
+func (s *State) TodayStats() DayStats {
+	today := s.now().Format("2006-01-02")
+	if s.Today.Date != today {
+		return DayStats{Date: today}
+	}
+	return s.Today
+}
+
+// RecentCalls returns up to n of the latest calls, newest first.
+func (s *State) RecentCalls(n int) []Call {
+	calls := make([]Call, 0, n)
+	for i := len(s.Calls) - 1; i >= 0 && len(calls) < n; i-- {
+		calls = append(calls, s.Calls[i])
+	}
+	return calls
+}
+
+// RecentAttempts returns up to n of the latest logins and failed attempts,
+// newest first.
+func (s *State) RecentAttempts(n int) []Call {
+	attempts := make([]Call, 0, n)
+	calls, failures := len(s.Calls)-1, len(s.Failures)-1
+	for len(attempts) < n && (calls >= 0 || failures >= 0) {
+		if failures < 0 || (calls >= 0 && !s.Calls[calls].Time.Before(s.Failures[failures].Time)) {
+			attempts = append(attempts, s.Calls[calls])
+			calls--
+		} else {
+			attempts = append(attempts, s.Failures[failures])
+			failures--
+		}
+	}
+	return attempts
+}
+
+// Online returns the number of nodes in use, not counting what may be bots.
+func (s *State) Online() int {
+	online := 0
+	for _, status := range s.nodes {
+		if !s.bot(status, s.now()) {
+			online++
+		}
+	}
+	return online
+}
+
+// Node returns the status of a node, or the idle status if nobody is on it.
+// Unknown callers only show once they've been connected for BotTime, so bots
+// that drop before the login prompt never clutter the node table.
+func (s *State) Node(nodeNum int) NodeStatus {
+	if status, exists := s.nodes[strconv.Itoa(nodeNum)]; exists && !s.bot(status, s.now()) {
+		return status
+	}
+	return NodeStatus{User: waitingUser, Location: waitingLocation}
+}
+
+// now is the time open calls are judged by: the clock, or the time of the
+// latest event when the log is ahead of it. Finished calls are judged by the
+// log's own times, so this keeps the node table and the counts in step even
+// when the log and the clock disagree.
+func (s *State) now() time.Time {
+	now := s.clock()
+	if s.latest.After(now) {
+		return s.latest
+	}
+	return now
+}
+
+// bot reports whether a caller still looks like a bot at the given time: not
+// logged in or signing up, and connected for less than BotTime.
+func (s *State) bot(status NodeStatus, at time.Time) bool {
+	return status.User == unknownUser && at.Sub(status.Since) < s.BotTime
+}
+
+// Counter returns the counter with the given name, creating it if needed.
+func (s *State) Counter(name string) *Counter {
+	for i := range s.Counters {
+		if s.Counters[i].Name == name {
+			return &s.Counters[i]
+		}
+	}
+	s.Counters = append(s.Counters, Counter{Name: name, Label: name})
+	return &s.Counters[len(s.Counters)-1]
+}
+
+// Touch marks the nodes a caller from the given IP is on as changed, as when
+// the IP's hostname comes in.
+func (s *State) Touch(ip string) {
+	for node, status := range s.nodes {
+		if nodeNum, err := strconv.Atoi(node); err == nil && sameIP(status.IP, ip) {
+			s.dirty[nodeNum] = true
+		}
+	}
+}
+
+// Dirty returns the nodes changed since the last call, in node order,
+// including the nodes of unknown callers that have just stayed long enough
+// to show.
+func (s *State) Dirty() []int {
+	now := s.now()
+	for node, status := range s.nodes {
+		if status.User == unknownUser && !status.shown && !s.bot(status, now) {
+			status.shown = true
+			s.nodes[node] = status
+			if nodeNum, err := strconv.Atoi(node); err == nil {
+				s.dirty[nodeNum] = true
+			}
+		}
+	}
+	nodes := make([]int, 0, len(s.dirty))
+	for nodeNum := range s.dirty {
+		nodes = append(nodes, nodeNum)
+	}
+	sort.Ints(nodes)
+	s.dirty = make(map[int]bool)
+	return nodes
+}
+
+// Replay applies every event in a log file, which may be gzipped, to the state.
+// With a tracker, the events go to it too and the calls they finish are
+// returned, for the history.
+func (s *State) Replay(logFilePath string, parser *Parser, tracker *SessionTracker) ([]Session, error) {
+	file, err := openLog(logFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var sessions []Session
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if ev, ok := parser.Parse(scanner.Text()); ok {
+			s.Apply(ev)
+			if tracker == nil {
+				continue
+			}
+			if session, done := tracker.Apply(ev); done {
+				sessions = append(sessions, session)
+			}
+		}
+	}
+	return sessions, scanner.Err()
+}
+
+// describeLocation builds the Location column text for menu and activity events.
+func describeLocation(ev Event) string {
+	if ev.Label != "" {
+		return "At " + ev.Label
+	}
+	if ev.Type == EventMenu {
+		menuName := strings.Title(strings.TrimSuffix(filepath.Base(ev.Location), ".toml")) // Capitalize the menu name
+		return "At " + menuName + " Menu"
+	}
+
+	// Simplify the location and handle specific cases
+	location := strings.TrimPrefix(ev.Location, "menu ")
+	location = strings.TrimPrefix(location, "menus/")
+	location = strings.TrimSuffix(location, ".toml")
+	return "At " + strings.Title(location)
+}
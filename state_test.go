@@ -0,0 +1,223 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogoffClass(t *testing.T) {
+	const botTime = 15 * time.Second
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	connect := func(seconds int) Event {
+		return Event{Type: EventConnect, Time: at(seconds), Node: "1", IP: "203.0.113.7"}
+	}
+	logoff := func(seconds int) Event {
+		return Event{Type: EventLogoff, Time: at(seconds), Node: "1"}
+	}
+
+	tests := []struct {
+		name    string
+		events  []Event
+		class   string // Session.Class of the finished call, empty when none is tracked
+		want    DayStats
+		failure string // Failure added to the last callers, if any
+	}{
+		{
+			name:   "human",
+			events: []Event{connect(0), {Type: EventLogin, Time: at(5), Node: "1", User: "Sysop"}, logoff(300)},
+			class:  classHuman,
+			want:   DayStats{Calls: 1},
+		},
+		{
+			name:   "quick human",
+			events: []Event{connect(0), {Type: EventLogin, Time: at(2), Node: "1", User: "Sysop"}, logoff(4)},
+			class:  classHuman,
+			want:   DayStats{Calls: 1},
+		},
+		{
+			name:   "bot",
+			events: []Event{connect(0), logoff(3)},
+			class:  classBot,
+			want:   DayStats{Bots: 1},
+		},
+		{
+			name:    "failed login",
+			events:  []Event{connect(0), logoff(40)},
+			class:   classFailed,
+			want:    DayStats{Failed: 1},
+			failure: failureNoLogin,
+		},
+		{
+			name:    "failed login right at the bot time",
+			events:  []Event{connect(0), logoff(15)},
+			class:   classFailed,
+			want:    DayStats{Failed: 1},
+			failure: failureNoLogin,
+		},
+		{
+			name:    "abandoned signup",
+			events:  []Event{connect(0), {Type: EventNewUser, Time: at(2), Node: "1"}, logoff(90)},
+			class:   classAbandoned,
+			want:    DayStats{NewUsers: 1, Abandoned: 1},
+			failure: failureAbandoned,
+		},
+		{
+			// Only the end of the call is in the log
+			name: "logged in before the log was rotated",
+			events: []Event{
+				{Type: EventMenu, Time: at(0), Node: "1", User: "Sysop", Location: "main.toml"},
+				logoff(5),
+			},
+			want: DayStats{},
+		},
+		{
+			// The connection was rotated away, the login wasn't
+			name:   "connected before the log was rotated",
+			events: []Event{{Type: EventLogin, Time: at(0), Node: "1", User: "Sysop"}, logoff(5)},
+			class:  classHuman,
+			want:   DayStats{Calls: 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state := NewState(4, "None")
+			state.BotTime = botTime
+			tracker := NewSessionTracker()
+			var sessions []Session
+			for _, ev := range test.events {
+				state.Apply(ev)
+				if session, done := tracker.Apply(ev); done {
+					sessions = append(sessions, session)
+				}
+			}
+
+			test.want.Date = start.Format("2006-01-02")
+			if state.Today != test.want {
+				t.Errorf("today = %+v, want %+v", state.Today, test.want)
+			}
+			switch {
+			case test.failure == "" && len(state.Failures) > 0:
+				t.Errorf("failures = %+v, want none", state.Failures)
+			case test.failure != "" && (len(state.Failures) != 1 || state.Failures[0].Failure != test.failure || state.Failures[0].IP != "203.0.113.7"):
+				t.Errorf("failures = %+v, want one %s from 203.0.113.7", state.Failures, test.failure)
+			}
+
+			switch {
+			case test.class == "" && len(sessions) > 0:
+				t.Errorf("sessions = %+v, want none", sessions)
+			case test.class != "" && len(sessions) != 1:
+				t.Errorf("%d sessions, want 1", len(sessions))
+			case test.class != "":
+				if class := sessions[0].Class(botTime); class != test.class {
+					t.Errorf("class = %q, want %q", class, test.class)
+				}
+			}
+		})
+	}
+}
+
+func TestOnlineHidesBots(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	now := start
+	state := NewState(4, "None")
+	state.BotTime = 15 * time.Second
+	state.clock = func() time.Time { return now }
+
+	state.Apply(Event{Type: EventConnect, Time: start, Node: "1", IP: "203.0.113.7"})
+	state.Apply(Event{Type: EventConnect, Time: start, Node: "2", IP: "198.51.100.1"})
+	state.Apply(Event{Type: EventLogin, Time: start.Add(time.Second), Node: "2", User: "Sysop"})
+	if online := state.Online(); online != 1 {
+		t.Errorf("online = %d, want 1 while node 1 may be a bot", online)
+	}
+	if user := state.Node(1).User; user != waitingUser {
+		t.Errorf("node 1 = %q, want it shown as waiting", user)
+	}
+
+	now = start.Add(20 * time.Second)
+	if online := state.Online(); online != 2 {
+		t.Errorf("online = %d, want 2 after the bot time", online)
+	}
+	if user := state.Node(1).User; user != unknownUser {
+		t.Errorf("node 1 = %q, want %q", user, unknownUser)
+	}
+	if dirty := state.Dirty(); len(dirty) != 2 {
+		t.Errorf("dirty = %v, want nodes 1 and 2", dirty)
+	}
+}
+
+func TestOnlineLogAheadOfClock(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	state := NewState(4, "None")
+	state.BotTime = 15 * time.Second
+	// The clock is behind the log, as when replaying a log written on
+	// another machine
+	state.clock = func() time.Time { return start.Add(-time.Minute) }
+
+	state.Apply(Event{Type: EventConnect, Time: start, Node: "1", IP: "203.0.113.7"})
+	state.Apply(Event{Type: EventMenu, Time: start.Add(20 * time.Second), Node: "2", User: "Sysop", Location: "main.toml"})
+	// By the log's time node 1 has been connected for 20s, as the count of
+	// its logoff would find
+	if online := state.Online(); online != 2 {
+		t.Errorf("online = %d, want 2", online)
+	}
+	if today := state.TodayStats(); today.Date != start.Format("2006-01-02") {
+		t.Errorf("today = %s, want %s", today.Date, start.Format("2006-01-02"))
+	}
+}
+
+func TestReplayAcrossRotation(t *testing.T) {
+	dir := t.TempDir()
+	previous := filepath.Join(dir, "talisman.log.1")
+	current := filepath.Join(dir, "talisman.log")
+	// bob called over midnight, when the log was rotated
+	writeLog := func(path string, modified time.Time, lines ...string) {
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	midnight := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	writeLog(previous, midnight,
+		"2026-10-18 23:58:02 INFO: Connection From: 203.0.113.7 on Node 1",
+		"2026-10-18 23:58:11 INFO: bob logged in on node 1",
+	)
+	writeLog(current, midnight.Add(time.Hour),
+		"2026-10-19 00:05:40 INFO: bob running door lord on node 1",
+		"2026-10-19 00:10:27 INFO: Node 1 logged off",
+	)
+	parser := &Parser{}
+
+	// As on startup: the rotated log, then the current one with the same tracker
+	state := NewState(4, "None")
+	tracker := NewSessionTracker()
+	sessions, err := state.Replay(previous, parser, tracker)
+	if err != nil || len(sessions) != 0 {
+		t.Fatalf("rotated log replay = %+v, %v; want the call still open", sessions, err)
+	}
+	if more, err := state.Replay(current, parser, tracker); err != nil || len(more) != 1 {
+		t.Fatalf("current log replay = %+v, %v; want one call", more, err)
+	} else {
+		sessions = more
+	}
+
+	imported, err := LoadHistory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, added, err := ImportLogs(current, parser, imported); err != nil || added != 1 {
+		t.Fatalf("ImportLogs added %d, %v; want 1", added, err)
+	}
+	if got, want := sessions[0].key(), imported.Sessions()[0].key(); got != want {
+		t.Errorf("session key = %q, want %q as imported", got, want)
+	}
+
+	if user := findLastLoggedOffUser(previous, current, maxLogLines); user != "bob" {
+		t.Errorf("last user = %q, want bob", user)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	statsDays = 30 // Days in the calls per day chart
+	statsTop  = 3  // Entries in the busiest nodes and top doors lists
+)
+
+// Ranked is a name with a count, for top lists.
+type Ranked struct {
+	Name  string
+	Count int
+}
+
+// HistoryStats are the figures on the stats screen.
+type HistoryStats struct {
+	Hourly    [24]int        // Calls per hour today
+	Daily     [statsDays]int // Calls per day, oldest first, ending today
+	Nodes     []Ranked       // Calls per node, busiest first
+	Doors     []Ranked       // Launches per door, most used first
+	PeakUsers int            // Most users logged in at once
+	PeakTime  time.Time      // When PeakUsers was first reached
+	Classes   map[string]int // Connections by class over the days charted
+}
+
+// ComputeStats works out the stats screen figures from the session history.
+// Calls are logins, not counting excludeUser; connections dropped without a
+// login sooner than botTime are bots.
+func ComputeStats(sessions []Session, now time.Time, botTime time.Duration) HistoryStats {
+	stats := HistoryStats{Classes: make(map[string]int)}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	days := make(map[string]int, statsDays)
+	for day := 0; day < statsDays; day++ {
+		days[today.AddDate(0, 0, day-(statsDays-1)).Format("2006-01-02")] = day
+	}
+
+	nodes := make(map[string]int)
+	doors := make(map[string]int)
+	type change struct {
+		at    time.Time
+		delta int
+	}
+	var changes []change
+
+	for _, session := range sessions {
+		if _, ok := days[session.Start.In(now.Location()).Format("2006-01-02")]; ok {
+			stats.Classes[session.Class(botTime)]++
+		}
+		for _, door := range session.Doors {
+			doors[door]++
+		}
+		if session.Login.IsZero() || session.User == excludeUser {
+			continue
+		}
+		nodes[session.Node]++
+		changes = append(changes, change{session.Login, 1}, change{session.End, -1})
+
+		login := session.Login.In(now.Location())
+		if !login.Before(today) {
+			stats.Hourly[login.Hour()]++
+		}
+		if day, ok := days[login.Format("2006-01-02")]; ok {
+			stats.Daily[day]++
+		}
+	}
+
+	// Peak concurrency: walk logins and logoffs in time order
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].at.Equal(changes[j].at) {
+			return changes[i].delta < changes[j].delta // Logoffs first
+		}
+		return changes[i].at.Before(changes[j].at)
+	})
+	online := 0
+	for _, c := range changes {
+		online += c.delta
+		if online > stats.PeakUsers {
+			stats.PeakUsers, stats.PeakTime = online, c.at
+		}
+	}
+
+	stats.Nodes = rank(nodes)
+	stats.Doors = rank(doors)
+	return stats
+}
+
+// rank sorts counts highest first, then by name.
+func rank(counts map[string]int) []Ranked {
+	ranked := make([]Ranked, 0, len(counts))
+	for name, count := range counts {
+		ranked = append(ranked, Ranked{name, count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	return ranked
+}
+
+// barChart draws values as vertical bars height rows tall, using half block
+// characters for twice the resolution. It returns the rows, top first.
+func barChart(values []int, height, barWidth int) []string {
+	peak := 1
+	for _, value := range values {
+		peak = max(peak, value)
+	}
+	rows := make([]string, height)
+	for row := range rows {
+		var line strings.Builder
+		level := (height - row) * 2 // Half blocks up to the top of this row
+		for _, value := range values {
+			halves := (value*height*2 + peak - 1) / peak // Round up so any call shows
+			cell := " "
+			switch {
+			case halves >= level:
+				cell = "█"
+			case halves == level-1:
+				cell = "▄"
+			}
+			line.WriteString(strings.Repeat(cell, barWidth-1) + " ")
+		}
+		rows[row] = line.String()
+	}
+	return rows
+}
+
+// drawStatsScreen shows the call statistics in place of the WFC screen.
+func (s *Screen) drawStatsScreen() {
+	stats := ComputeStats(s.History.Sessions(), time.Now(), s.State.BotTime)
+	s.historyDrawn = len(s.History.Sessions())
+	heading := func(x, y int, text string) {
+		MoveCursor(x, y)
+		fmt.Fprint(Output, formatCell(text, len(text), s.Theme.LastUserLabel))
+	}
+	chart := func(x, y int, rows []string) {
+		for i, row := range rows {
+			MoveCursor(x, y+i)
+			fmt.Fprint(Output, s.Theme.Background+s.Theme.User+row+Reset)
+		}
+	}
+	label := func(x, y int, text string) {
+		MoveCursor(x, y)
+		fmt.Fprint(Output, formatCell(text, len(text), s.Theme.Location))
+	}
+
+	MoveCursor(1, 1)
+	PrintSpaces(s.Width, s.Theme.Footer)
+	MoveCursor(1, 1)
+	fmt.Fprint(Output, s.Theme.Footer+s.Theme.FooterLabel+" Call Statistics"+Reset)
+
+	// Calls per hour today, three columns an hour
+	peakHour := 0
+	for _, calls := range stats.Hourly {
+		peakHour = max(peakHour, calls)
+	}
+	heading(1, 3, fmt.Sprintf(" Calls per hour today (most %d)", peakHour))
+	chart(2, 4, barChart(stats.Hourly[:], 6, 3))
+	for hour := 0; hour < 24; hour += 3 {
+		label(2+hour*3, 10, fmt.Sprintf("%02d", hour))
+	}
+
+	// Calls per day, two columns a day
+	peakDay := 0
+	for _, calls := range stats.Daily {
+		peakDay = max(peakDay, calls)
+	}
+	heading(1, 12, fmt.Sprintf(" Calls per day, last %d days (most %d)", statsDays, peakDay))
+	chart(2, 13, barChart(stats.Daily[:], 5, 2))
+	first := time.Now().AddDate(0, 0, -(statsDays - 1))
+	for day := 0; day < statsDays; day += 7 {
+		label(2+day*2, 18, first.AddDate(0, 0, day).Format("01-02"))
+	}
+
+	// Top lists side by side
+	top := func(x int, title string, ranked []Ranked) {
+		heading(x, 20, title)
+		for i := 0; i < statsTop && i < len(ranked); i++ {
+			label(x, 21+i, fmt.Sprintf(" %-16s %5d", PadOrTruncate(ranked[i].Name, 16), ranked[i].Count))
+		}
+	}
+	nodes := make([]Ranked, len(stats.Nodes))
+	for i, node := range stats.Nodes {
+		nodes[i] = Ranked{"Node " + node.Name, node.Count}
+	}
+	top(1, " Busiest nodes", nodes)
+	top(27, " Top doors", stats.Doors)
+	heading(53, 20, " Peak users online")
+	if stats.PeakUsers > 0 {
+		label(53, 21, fmt.Sprintf(" %d on %s", stats.PeakUsers, stats.PeakTime.Format("2006-01-02 15:04")))
+	}
+	heading(53, 22, fmt.Sprintf(" Connections, last %d days", statsDays))
+	label(53, 23, fmt.Sprintf(" %d human, %d bot, %d failed", stats.Classes[classHuman], stats.Classes[classBot], stats.Classes[classFailed]+stats.Classes[classAbandoned]))
+}
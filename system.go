@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var eventTimePattern = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ScheduledEvent is a recurring BBS event, such as nightly maintenance, shown
+// on the system panel when it's next due.
+type ScheduledEvent struct {
+	Days   []time.Weekday // Empty for every day
+	Minute int            // Minutes after midnight
+	Name   string
+}
+
+// parseScheduledEvent reads an event entry: optional comma separated days,
+// the time and a description, e.g. "04:00 Nightly maintenance" or
+// "sat,sun 03:30 Message base pack".
+func parseScheduledEvent(entry string) (ScheduledEvent, error) {
+	fields := strings.Fields(entry)
+	event := ScheduledEvent{}
+	if len(fields) > 0 && !eventTimePattern.MatchString(fields[0]) {
+		for _, day := range strings.Split(strings.ToLower(fields[0]), ",") {
+			weekday, ok := weekdays[day]
+			if !ok {
+				return ScheduledEvent{}, fmt.Errorf("event %q: unknown day %q", entry, day)
+			}
+			event.Days = append(event.Days, weekday)
+		}
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return ScheduledEvent{}, fmt.Errorf("event %q needs a time and a description", entry)
+	}
+	matches := eventTimePattern.FindStringSubmatch(fields[0])
+	if matches == nil {
+		return ScheduledEvent{}, fmt.Errorf("event %q: invalid time %q", entry, fields[0])
+	}
+	event.Minute = atoiOrZero(matches[1])*60 + atoiOrZero(matches[2])
+	event.Name = strings.Join(fields[1:], " ")
+	return event, nil
+}
+
+// Next returns the next time the event runs after now.
+func (e ScheduledEvent) Next(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for day := 0; day <= 7; day++ {
+		next := midnight.AddDate(0, 0, day).Add(time.Duration(e.Minute) * time.Minute)
+		if next.After(now) && e.runsOn(next.Weekday()) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+func (e ScheduledEvent) runsOn(weekday time.Weekday) bool {
+	if len(e.Days) == 0 {
+		return true
+	}
+	for _, day := range e.Days {
+		if day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// nextEvent returns the soonest of events and when it runs.
+func nextEvent(events []ScheduledEvent, now time.Time) (ScheduledEvent, time.Time, bool) {
+	var soonest ScheduledEvent
+	var at time.Time
+	for _, event := range events {
+		if next := event.Next(now); at.IsZero() || next.Before(at) {
+			soonest, at = event, next
+		}
+	}
+	return soonest, at, !at.IsZero()
+}
+
+// hostUptime reads how long the machine has been up from /proc/uptime. It
+// reports false on systems without /proc.
+func hostUptime() (time.Duration, bool) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// loadAverage reads the 1, 5 and 15 minute load averages from /proc/loadavg.
+func loadAverage() (string, bool) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return "", false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return "", false
+	}
+	return strings.Join(fields[:3], " "), true
+}
+
+// formatUptime formats a duration as days, hours and minutes.
+func formatUptime(d time.Duration) string {
+	minutes := int(d.Minutes())
+	days, hours := minutes/(24*60), minutes/60%24
+	if days > 0 {
+		return fmt.Sprintf("%dd %02dh %02dm", days, hours, minutes%60)
+	}
+	return fmt.Sprintf("%02dh %02dm", hours, minutes%60)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Bundled themes, always available for cycling
+//
+//go:embed themes/*.ini
+var bundledThemes embed.FS
+
+// Theme file the other themes fall back to for missing roles
+const defaultThemeFile = "talisman.ini"
+
+var colorNames = map[string]int{
+	"black":   0,
+	"red":     1,
+	"green":   2,
+	"yellow":  3,
+	"blue":    4,
+	"magenta": 5,
+	"cyan":    6,
+	"white":   7,
+}
+
+// Theme holds the ANSI sequence drawn before each UI role. Once loaded every
+// role includes the background, so cells keep the theme's background after a
+// reset.
+type Theme struct {
+	ID   string // File name without extension
+	Name string
+
+	Background    string
+	Node          string
+	NodeLabel     string
+	User          string
+	UserWaiting   string
+	UserLabel     string
+	Location      string
+	LocationLabel string
+	LastUserLabel string
+	LastUser      string
+	Separator     string
+	Footer        string
+	FooterLabel   string
+}
+
+// roles maps the keys in a theme's [colors] section to its fields.
+func (t *Theme) roles() map[string]*string {
+	return map[string]*string{
+		"node":            &t.Node,
+		"node label":      &t.NodeLabel,
+		"user":            &t.User,
+		"user waiting":    &t.UserWaiting,
+		"user label":      &t.UserLabel,
+		"location":        &t.Location,
+		"location label":  &t.LocationLabel,
+		"last user label": &t.LastUserLabel,
+		"last user":       &t.LastUser,
+		"separator":       &t.Separator,
+		"footer":          &t.Footer,
+		"footer label":    &t.FooterLabel,
+	}
+}
+
+// LoadThemes returns the bundled themes followed by any *.ini themes in dir,
+// which may be empty.
+func LoadThemes(dir string) ([]*Theme, error) {
+	bundled, err := fs.Sub(bundledThemes, "themes")
+	if err != nil {
+		return nil, err
+	}
+	defaultTheme, err := loadTheme(bundled, defaultThemeFile, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	themes := []*Theme{defaultTheme}
+	sources := []fs.FS{bundled}
+	if dir != "" {
+		sources = append(sources, os.DirFS(dir))
+	}
+	for _, source := range sources {
+		names, err := fs.Glob(source, "*.ini")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if source == bundled && name == defaultThemeFile {
+				continue
+			}
+			theme, err := loadTheme(source, name, defaultTheme)
+			if err != nil {
+				return nil, fmt.Errorf("theme %s: %w", name, err)
+			}
+			themes = append(themes, theme)
+		}
+	}
+
+	for _, theme := range themes {
+		theme.applyBackground()
+	}
+	return themes, nil
+}
+
+// FindTheme returns the index of the theme with the given ID or name.
+func FindTheme(themes []*Theme, name string) (int, error) {
+	for i, theme := range themes {
+		if strings.EqualFold(theme.ID, name) || strings.EqualFold(theme.Name, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no theme named %q", name)
+}
+
+// loadTheme reads a theme file. Roles it doesn't set are copied from fallback,
+// which must not have had its background applied yet.
+func loadTheme(source fs.FS, name string, fallback *Theme) (*Theme, error) {
+	content, err := fs.ReadFile(source, name)
+	if err != nil {
+		return nil, err
+	}
+	// Allow "#rrggbb" values, which would otherwise start a comment
+	cfg, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, content)
+	if err != nil {
+		return nil, err
+	}
+
+	id := strings.TrimSuffix(filepath.Base(name), ".ini")
+	theme := &Theme{}
+	if fallback != nil {
+		*theme = *fallback
+	}
+	theme.ID = id
+	theme.Name = cfg.Section(ini.DefaultSection).Key("name").MustString(id)
+
+	colors := cfg.Section("colors")
+	targets := theme.roles()
+	targets["background"] = &theme.Background
+	for key, target := range targets {
+		if !colors.HasKey(key) {
+			continue
+		}
+		if *target, err = parseColor(colors.Key(key).String()); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return theme, nil
+}
+
+// applyBackground prefixes every role with the background so cells keep it
+// after a reset.
+func (t *Theme) applyBackground() {
+	for _, target := range t.roles() {
+		*target = t.Background + *target
+	}
+}
+
+// parseColor turns a color spec into ANSI sequences. A spec is a foreground,
+// "on" and a background, either part optional: "bright cyan", "black on
+// white", "on blue". Colors are one of the 8 names (optionally "bright"), a
+// 256-color index, or a #rrggbb truecolor value.
+func parseColor(spec string) (string, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" {
+		return "", nil
+	}
+
+	fg, bg := spec, ""
+	if strings.HasPrefix(spec, "on ") {
+		fg, bg = "", strings.TrimPrefix(spec, "on ")
+	} else if i := strings.Index(spec, " on "); i != -1 {
+		fg, bg = spec[:i], spec[i+len(" on "):]
+	}
+
+	sequence := ""
+	if fg != "" {
+		codes, err := colorCodes(strings.TrimSpace(fg), false)
+		if err != nil {
+			return "", err
+		}
+		sequence += Esc + codes + "m"
+	}
+	if bg != "" {
+		codes, err := colorCodes(strings.TrimSpace(bg), true)
+		if err != nil {
+			return "", err
+		}
+		sequence += Esc + codes + "m"
+	}
+	return sequence, nil
+}
+
+// colorCodes returns the SGR parameters for one color.
+func colorCodes(color string, background bool) (string, error) {
+	base := 30
+	extended := "38"
+	if background {
+		base = 40
+		extended = "48"
+	}
+
+	if strings.HasPrefix(color, "#") && len(color) == 7 {
+		rgb, err := strconv.ParseUint(color[1:], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid color %q", color)
+		}
+		return fmt.Sprintf("%s;2;%d;%d;%d", extended, rgb>>16, (rgb>>8)&0xff, rgb&0xff), nil
+	}
+	if index, err := strconv.Atoi(color); err == nil {
+		if index < 0 || index > 255 {
+			return "", fmt.Errorf("color index %d out of range", index)
+		}
+		return fmt.Sprintf("%s;5;%d", extended, index), nil
+	}
+
+	bright := strings.HasPrefix(color, "bright ")
+	index, ok := colorNames[strings.TrimPrefix(color, "bright ")]
+	if !ok {
+		return "", fmt.Errorf("unknown color %q", color)
+	}
+	switch {
+	case bright && background:
+		return strconv.Itoa(100 + index), nil // aixterm bright background
+	case bright:
+		return fmt.Sprintf("%d;1", base+index), nil
+	}
+	return strconv.Itoa(base + index), nil
+}
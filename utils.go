@@ -3,12 +3,12 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 	"unicode/utf8"
 
 	"golang.org/x/term"
@@ -80,42 +80,111 @@ const (
 	BgCyanHi    = Esc + "46;1m"
 	BgWhiteHi   = Esc + "47;1m"
 
-	Reset = Esc + "0m"
+	Reverse = Esc + "7m"
+	Reset   = Esc + "0m"
 )
 
-// HandleKeyPress handles detecting key press events
-func HandleKeyPress() {
+// Output is where all screen drawing is written. It defaults to stdout and is
+// pointed at the caller's connection when running as a door.
+var Output io.Writer = os.Stdout
+
+// ReadKeys sends every key pressed on stdin to keys
+func ReadKeys(keys chan<- byte) {
+	b := make([]byte, 1)
 	for {
-		b := make([]byte, 1)
 		_, err := os.Stdin.Read(b)
 		if err != nil {
 			log.Printf("Error reading input: %v", err)
-			continue
-		}
-		// Exit if 'q', 'Q', or 'Esc' is pressed
-		if b[0] == 'q' || b[0] == 'Q' || b[0] == 27 { // 27 is the ASCII code for the Escape key
-			CursorShow()
-			break
+			return
 		}
+		keys <- b[0]
 	}
 }
 
+// Any ANSI escape sequence
+var ansiSequencePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// Pipe (|07), PCBoard (@X1F) and Wildcat (@1F@) color codes
+var colorCodePattern = regexp.MustCompile(`\|([0-9]{2})|@X([0-9A-Fa-f]{2})|@([0-9A-Fa-f]{2})@`)
+
+// DOS color numbers in ANSI order: DOS counts black, blue, green, cyan, red...
+var dosToAnsi = [8]int{0, 4, 2, 6, 1, 5, 3, 7}
+
+// TranslateColorCodes converts pipe, PCBoard @X and Wildcat @..@ color codes
+// to ANSI sequences. Codes set DOS attributes, so each one emits the full
+// attribute to handle bright foregrounds and blinking backgrounds.
+func TranslateColorCodes(text string) string {
+	fg, bg, blink := 7, 0, false
+
+	return colorCodePattern.ReplaceAllStringFunc(text, func(code string) string {
+		matches := colorCodePattern.FindStringSubmatch(code)
+		switch {
+		case matches[1] != "":
+			// |00-|15 foreground, |16-|23 background, |24-|31 blinking background
+			n, _ := strconv.Atoi(matches[1])
+			switch {
+			case n < 16:
+				fg = n
+			case n < 24:
+				bg, blink = n-16, false
+			case n < 32:
+				bg, blink = n-24, true
+			default:
+				return code
+			}
+		default:
+			// PCBoard and Wildcat codes are a hex attribute byte, background first
+			hex := matches[2] + matches[3]
+			attr, _ := strconv.ParseUint(hex, 16, 8)
+			fg, bg, blink = int(attr&0x0f), int(attr>>4)&0x07, attr&0x80 != 0
+		}
+
+		sgr := "0"
+		if fg > 7 {
+			sgr += ";1"
+		}
+		if blink {
+			sgr += ";5"
+		}
+		return Esc + sgr + fmt.Sprintf(";%d;%dm", 30+dosToAnsi[fg&7], 40+dosToAnsi[bg])
+	})
+}
+
+// StripColorCodes removes pipe, PCBoard and Wildcat color codes, for text
+// such as names that's shown in the colors around it.
+func StripColorCodes(text string) string {
+	return StripAnsi(TranslateColorCodes(text))
+}
+
 // PrintSpaces prints a number of spaces equal to the terminal width with a given background color.
 func PrintSpaces(width int, bgColor string) {
-	spaces := strings.Repeat(" ", width) // Create a string with the number of spaces equal to the width
-	fmt.Print(bgColor + spaces + Reset)  // Print the spaces with the background color and reset at the end
+	spaces := strings.Repeat(" ", width)     // Create a string with the number of spaces equal to the width
+	fmt.Fprint(Output, bgColor+spaces+Reset) // Print the spaces with the background color and reset at the end
 }
 
-// PadOrTruncate handles padding or truncating the string while ignoring ANSI color codes
+// An ANSI color code at the start of a string
+var leadingAnsiPattern = regexp.MustCompile(`^\x1b\[[0-9;]*m`)
+
+// PadOrTruncate handles padding or truncating the string while ignoring ANSI color codes.
+// Characters are counted, not bytes, so names like São Paulo aren't cut mid-character.
 func PadOrTruncate(text string, width int) string {
 	plainText := StripAnsi(text)
-	textLen := len(plainText)
+	textLen := utf8.RuneCountInString(plainText)
 	if textLen == width {
 		return text
-	} else if textLen > width {
-		return text[:width]
+	} else if textLen < width {
+		return text + strings.Repeat(" ", width-textLen)
+	}
+	// Keep the first width characters, and the color codes among them
+	i := 0
+	for count := 0; count < width; count++ {
+		for code := leadingAnsiPattern.FindString(text[i:]); code != ""; code = leadingAnsiPattern.FindString(text[i:]) {
+			i += len(code)
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
 	}
-	return text + strings.Repeat(" ", width-textLen)
+	return text[:i]
 }
 
 // StripAnsi removes ANSI color codes from a string
@@ -126,67 +195,67 @@ func StripAnsi(str string) string {
 
 // ClearScreen clears the terminal screen
 func ClearScreen() {
-	fmt.Print("\033[H\033[2J") // ANSI escape to clear screen and move to top
+	fmt.Fprint(Output, "\033[H\033[2J") // ANSI escape to clear screen and move to top
 }
 
 // MoveCursor moves the cursor to a specific position on the screen
 func MoveCursor(x, y int) {
-	fmt.Printf("\033[%d;%dH", y, x) // ANSI escape to move the cursor
+	fmt.Fprintf(Output, "\033[%d;%dH", y, x) // ANSI escape to move the cursor
 }
 
 // Move the cursor n cells to up.
 func CursorUp(n int) {
-	fmt.Printf(Esc+"%dA", n)
+	fmt.Fprintf(Output, Esc+"%dA", n)
 }
 
 // Move the cursor n cells to down.
 func CursorDown(n int) {
-	fmt.Printf(Esc+"%dB", n)
+	fmt.Fprintf(Output, Esc+"%dB", n)
 }
 
 // Move the cursor n cells to right.
 func CursorForward(n int) {
-	fmt.Printf(Esc+"%dC", n)
+	fmt.Fprintf(Output, Esc+"%dC", n)
 }
 
 // Move the cursor n cells to left.
 func CursorBack(n int) {
-	fmt.Printf(Esc+"%dD", n)
+	fmt.Fprintf(Output, Esc+"%dD", n)
 }
 
 // Move cursor to beginning of the line n lines down.
 func CursorNextLine(n int) {
-	fmt.Printf(Esc+"%dE", n)
+	fmt.Fprintf(Output, Esc+"%dE", n)
 }
 
 // Move cursor to beginning of the line n lines up.
 func CursorPreviousLine(n int) {
-	fmt.Printf(Esc+"%dF", n)
+	fmt.Fprintf(Output, Esc+"%dF", n)
 }
 
 // Move cursor horizontally to x.
 func CursorHorizontalAbsolute(x int) {
-	fmt.Printf(Esc+"%dG", x)
+	fmt.Fprintf(Output, Esc+"%dG", x)
 }
 
 // Show the cursor.
 func CursorShow() {
-	fmt.Print(Esc + "?25h")
+	fmt.Fprint(Output, Esc+"?25h")
 }
 
 // Hide the cursor.
 func CursorHide() {
-	fmt.Print(Esc + "?25l")
+	fmt.Fprint(Output, Esc+"?25l")
 }
 
 // Save the screen.
 func SaveScreen() {
-	fmt.Print(Esc + "?47h")
+	fmt.Fprint(Output, Esc+"?47h")
 }
 
 // Restore the saved screen.
 func RestoreScreen() {
-	fmt.Print(Esc + "?47l")
+	fmt.Fprint(Output, Esc+"?47l")
 }
 
 func GetTermSize() (int, int, error) {
@@ -213,47 +282,46 @@ func ReadAnsiFile(filePath string) (string, error) {
 	return string(content), nil
 }
 
-// Print ANSI art with a delay between lines
-func PrintAnsi(artContent string, delay int, localDisplay bool) { // localDisplay as an argument for UTF-8 conversion
+// Print ANSI art at a baud rate, or at full speed when baud is 0
+func PrintAnsi(artContent string, baud int, localDisplay bool) { // localDisplay as an argument for UTF-8 conversion
 	noSauce := TrimStringFromSauce(artContent) // strip off the SAUCE metadata
 	lines := strings.Split(noSauce, "\r\n")
 
+	var out strings.Builder
 	for i, line := range lines {
 		if localDisplay {
 			// Convert line from CP437 to UTF-8
 			utf8Line, err := charmap.CodePage437.NewDecoder().String(line)
 			if err != nil {
-				fmt.Printf("Error converting to UTF-8: %v\n", err)
+				fmt.Fprintf(Output, "Error converting to UTF-8: %v\n", err)
 				continue
 			}
 			line = utf8Line
 		}
 
+		out.WriteString(line)
 		if i < len(lines)-1 && i != 24 { // Check for the 25th line (index 24)
-			fmt.Println(line) // Print with a newline
-		} else {
-			fmt.Print(line) // Print without a newline (for the 25th line and the last line of the art)
+			out.WriteString("\n") // Print with a newline, but not after the 25th line and the last line of the art
 		}
-		time.Sleep(time.Duration(delay) * time.Millisecond)
 	}
+	SendAtBaud(out.String(), baud, nil)
 }
 
-func TrimStringFromSauce(s string) string {
-	if idx := strings.Index(s, "COMNT"); idx != -1 {
-		string := s
-		delimiter := "COMNT"
-		leftOfDelimiter := strings.Split(string, delimiter)[0]
-		trim := TrimLastChar(leftOfDelimiter)
-		return trim
+// ansiSequenceAt returns the escape sequence starting at text[i], if any.
+func ansiSequenceAt(text string, i int) string {
+	if text[i] != 0x1b {
+		return ""
 	}
-	if idx := strings.Index(s, "SAUCE00"); idx != -1 {
-		string := s
-		delimiter := "SAUCE00"
-		leftOfDelimiter := strings.Split(string, delimiter)[0]
-		trim := TrimLastChar(leftOfDelimiter)
-		return trim
+	if loc := ansiSequencePattern.FindStringIndex(text[i:]); loc != nil && loc[0] == 0 {
+		return text[i : i+loc[1]]
 	}
-	return s
+	return ""
+}
+
+// TrimStringFromSauce returns the art without its SAUCE record, comments and EOF marker.
+func TrimStringFromSauce(s string) string {
+	content, _ := ParseSauce([]byte(s))
+	return string(content)
 }
 
 func TrimLastChar(s string) string {
@@ -271,18 +339,18 @@ func PrintAnsiLoc(artfile string, x int, y int) {
 	s := bufio.NewScanner(strings.NewReader(string(noSauce)))
 
 	for s.Scan() {
-		fmt.Fprintf(os.Stdout, Esc+strconv.Itoa(yLoc)+";"+strconv.Itoa(x)+"f"+s.Text())
+		fmt.Fprintf(Output, Esc+strconv.Itoa(yLoc)+";"+strconv.Itoa(x)+"f"+s.Text())
 		yLoc++
 	}
 }
 
 // Print text at an X, Y location
 func PrintStringLoc(text string, x int, y int) {
-	fmt.Fprintf(os.Stdout, Esc+strconv.Itoa(y)+";"+strconv.Itoa(x)+"f"+text)
+	fmt.Fprintf(Output, Esc+strconv.Itoa(y)+";"+strconv.Itoa(x)+"f"+text)
 
 }
 
 // Horizontally center some text.
 func CenterText(s string, w int) {
-	fmt.Fprintf(os.Stdout, (fmt.Sprintf("%[1]*s", -w, fmt.Sprintf("%[1]*s", (w+len(s))/2, s))))
+	fmt.Fprintf(Output, (fmt.Sprintf("%[1]*s", -w, fmt.Sprintf("%[1]*s", (w+len(s))/2, s))))
 }
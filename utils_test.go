@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestPadOrTruncate(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{"bob", 5, "bob  "},
+		{"São Paulo", 3, "São"},
+		{"Zürich", 8, "Zürich  "},
+		{"bücher.example", 4, "büch"},
+		// Color codes take no room and aren't cut
+		{"\x1b[31mZürich\x1b[0m", 3, "\x1b[31mZür"},
+		{"\x1b[31mZü\x1b[0m", 3, "\x1b[31mZü\x1b[0m "},
+	}
+	for _, test := range tests {
+		if got := PadOrTruncate(test.text, test.width); got != test.want {
+			t.Errorf("PadOrTruncate(%q, %d) = %q, want %q", test.text, test.width, got, test.want)
+		}
+	}
+}